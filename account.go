@@ -0,0 +1,380 @@
+package jpnic
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Account は1つの資源管理者のIPv4/IPv6用の証明書です。どちらか一方のみでも構いません。
+type Account struct {
+	// 検索結果に付与する識別名(空の場合はRyakusho)
+	Name string
+	// 資源管理者略称(空の場合はMultiClient.Discoverで取得します)
+	Ryakusho string
+	IPv4     *Config
+	IPv6     *Config
+}
+
+// MultiClient は複数の資源管理者・証明書を保持し、操作毎に使用する証明書を選択します。
+// IPv4の操作はIPv4の証明書、IPv6の操作はIPv6の証明書を使い、資源管理者は略称で選択します。
+type MultiClient struct {
+	Accounts []*Account
+}
+
+// AccountError は1つのアカウントでのエラーです。
+type AccountError struct {
+	Account string
+	Err     error
+}
+
+func (e *AccountError) Error() string {
+	return e.Account + ": " + e.Err.Error()
+}
+
+func (e *AccountError) Unwrap() error {
+	return e.Err
+}
+
+// FanOutError は全アカウントへの検索の内、一部のアカウントで失敗した場合に返されます。
+// 成功したアカウントの結果はエラーと共に返されます。
+type FanOutError []*AccountError
+
+func (e FanOutError) Error() string {
+	var errs []string
+	for _, err := range e {
+		errs = append(errs, err.Error())
+	}
+	return strings.Join(errs, "\n")
+}
+
+type AccountIPv4 struct {
+	Account string `json:"account"`
+	InfoIPv4
+}
+
+type AccountIPv6 struct {
+	Account string `json:"account"`
+	InfoIPv6
+}
+
+type AccountJPNICHandle struct {
+	Account string `json:"account"`
+	JPNICHandleDetail
+}
+
+type AccountRequest struct {
+	Account string `json:"account"`
+	RequestInfo
+}
+
+type AccountResource struct {
+	Account string `json:"account"`
+	ResourceInfo
+}
+
+// バージョン指定の無い操作(JPNICハンドル・申請一覧・資源管理者情報)に使う証明書の指定
+const anyVersion = 0
+
+func (a *Account) name() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.Ryakusho
+}
+
+// config はversion(4/6)に対応する証明書を返します。anyVersionの場合はIPv4(無ければIPv6)の証明書を返します。
+func (a *Account) config(version int) *Config {
+	switch version {
+	case 4:
+		return a.IPv4
+	case 6:
+		return a.IPv6
+	}
+	if a.IPv4 != nil {
+		return a.IPv4
+	}
+	return a.IPv6
+}
+
+// Account は略称(もしくはName)が一致するアカウントを返します。大文字小文字は区別しません。
+// 略称が空の場合、アカウントが1つだけであればそのアカウントを返します。
+func (m *MultiClient) Account(ryakusho string) (*Account, error) {
+	ryakusho = strings.TrimSpace(ryakusho)
+	if ryakusho == "" {
+		if len(m.Accounts) == 1 {
+			return m.Accounts[0], nil
+		}
+		return nil, fmt.Errorf("資源管理者略称が指定されていません")
+	}
+
+	for _, account := range m.Accounts {
+		if strings.EqualFold(account.Ryakusho, ryakusho) || strings.EqualFold(account.Name, ryakusho) {
+			return account, nil
+		}
+	}
+	return nil, fmt.Errorf("資源管理者 %s のアカウントが登録されていません", ryakusho)
+}
+
+// ConfigFor は略称とIPのバージョン(4/6)に対応する証明書を返します。
+func (m *MultiClient) ConfigFor(ryakusho string, version int) (*Config, error) {
+	account, err := m.Account(ryakusho)
+	if err != nil {
+		return nil, err
+	}
+
+	return account.configFor(version)
+}
+
+// configFor はconfigと同じ証明書を返します。証明書が無い場合はエラーを返します。
+func (a *Account) configFor(version int) (*Config, error) {
+	con := a.config(version)
+	if con != nil {
+		return con, nil
+	}
+	if version == anyVersion {
+		return nil, fmt.Errorf("資源管理者 %s の証明書が登録されていません", a.name())
+	}
+	return nil, fmt.Errorf("資源管理者 %s のIPv%dの証明書が登録されていません", a.name(), version)
+}
+
+// Discover は略称が設定されていないアカウントの資源管理者情報を取得し、略称を設定します。
+func (m *MultiClient) Discover() error {
+	var targets []*Account
+	for _, account := range m.Accounts {
+		if account.Ryakusho == "" {
+			targets = append(targets, account)
+		}
+	}
+
+	return fanOut(targets, anyVersion, func(_ int, account *Account, con *Config) error {
+		info, _, err := con.GetResourceManagement()
+		if err != nil {
+			return err
+		}
+		if info.ResourceManagerInfo.Ryakusyo == "" {
+			return fmt.Errorf("資源管理者略称を取得できませんでした")
+		}
+		account.Ryakusho = info.ResourceManagerInfo.Ryakusyo
+		return nil
+	})
+}
+
+// Send はNetworkの資源管理者略称とIPアドレスのバージョンに対応する証明書で申請します。
+func (m *MultiClient) Send(input WebTransaction) Result {
+	version := 4
+	if ip := net.ParseIP(strings.Split(strings.TrimSpace(input.Network.IPAddress), "/")[0]); ip != nil && ip.To4() == nil {
+		version = 6
+	}
+
+	con, err := m.ConfigFor(input.Network.Ryakusyo, version)
+	if err != nil {
+		return Result{Err: err}
+	}
+	return con.Send(input)
+}
+
+// GetIPUser はaccountのversion(4/6)の証明書で、検索結果のリンクから割り当て情報を取得します。
+func (m *MultiClient) GetIPUser(account string, version int, userURL string) (InfoDetail, error) {
+	con, err := m.ConfigFor(account, version)
+	if err != nil {
+		return InfoDetail{}, err
+	}
+	return con.GetIPUser(userURL)
+}
+
+// GetJPNICHandle はaccountの証明書でJPNICハンドルの情報を取得します。
+func (m *MultiClient) GetJPNICHandle(account, handle string) (JPNICHandleDetail, error) {
+	con, err := m.ConfigFor(account, anyVersion)
+	if err != nil {
+		return JPNICHandleDetail{}, err
+	}
+	return con.GetJPNICHandle(handle)
+}
+
+// RegisterHandle はaccountの証明書でJPNICハンドルを新規に登録し、受付番号を返します。
+func (m *MultiClient) RegisterHandle(account string, input JPNICHandleInput) (string, error) {
+	con, err := m.ConfigFor(account, anyVersion)
+	if err != nil {
+		return "", err
+	}
+	return con.RegisterHandle(input)
+}
+
+// ChangeUserInfo はaccountの証明書でJPNICハンドルの情報を変更し、受付番号を返します。
+func (m *MultiClient) ChangeUserInfo(account string, input JPNICHandleInput) (string, error) {
+	con, err := m.ConfigFor(account, anyVersion)
+	if err != nil {
+		return "", err
+	}
+	return con.ChangeUserInfo(input)
+}
+
+// GetRequestDetail はaccountの証明書で受付番号の申請情報(詳細)を取得します。
+func (m *MultiClient) GetRequestDetail(account, recepNo string) (RequestDetail, error) {
+	con, err := m.ConfigFor(account, anyVersion)
+	if err != nil {
+		return RequestDetail{}, err
+	}
+	return con.GetRequestDetail(recepNo)
+}
+
+// SearchIPv4 はIPv4の証明書を持つ全てのアカウントで同じ条件の検索を行い、アカウント名を付けて結果をまとめます。
+// accountを指定した場合はそのアカウントのみで検索します。(検索条件の資源管理者略称はアカウントの選択には使いません)
+func (m *MultiClient) SearchIPv4(account string, search SearchIPv4) ([]AccountIPv4, []AccountJPNICHandle, error) {
+	accounts, err := m.targets(account, 4)
+	if err != nil {
+		return nil, nil, err
+	}
+	infos := make([][]AccountIPv4, len(accounts))
+	handles := make([][]AccountJPNICHandle, len(accounts))
+
+	err = fanOut(accounts, 4, func(i int, account *Account, con *Config) error {
+		result, resultHandles, err := con.SearchIPv4(search)
+		for _, info := range result {
+			infos[i] = append(infos[i], AccountIPv4{Account: account.name(), InfoIPv4: info})
+		}
+		handles[i] = tagHandles(account, resultHandles)
+		return err
+	})
+
+	var allInfos []AccountIPv4
+	var allHandles []AccountJPNICHandle
+	for i := range accounts {
+		allInfos = append(allInfos, infos[i]...)
+		allHandles = append(allHandles, handles[i]...)
+	}
+	return allInfos, allHandles, err
+}
+
+// SearchIPv6 はIPv6の証明書を持つ全てのアカウントで同じ条件の検索を行い、アカウント名を付けて結果をまとめます。
+// accountを指定した場合はそのアカウントのみで検索します。(検索条件の資源管理者略称はアカウントの選択には使いません)
+func (m *MultiClient) SearchIPv6(account string, search SearchIPv6) ([]AccountIPv6, []AccountJPNICHandle, error) {
+	accounts, err := m.targets(account, 6)
+	if err != nil {
+		return nil, nil, err
+	}
+	infos := make([][]AccountIPv6, len(accounts))
+	handles := make([][]AccountJPNICHandle, len(accounts))
+
+	err = fanOut(accounts, 6, func(i int, account *Account, con *Config) error {
+		result, resultHandles, err := con.SearchIPv6(search)
+		for _, info := range result {
+			infos[i] = append(infos[i], AccountIPv6{Account: account.name(), InfoIPv6: info})
+		}
+		handles[i] = tagHandles(account, resultHandles)
+		return err
+	})
+
+	var allInfos []AccountIPv6
+	var allHandles []AccountJPNICHandle
+	for i := range accounts {
+		allInfos = append(allInfos, infos[i]...)
+		allHandles = append(allHandles, handles[i]...)
+	}
+	return allInfos, allHandles, err
+}
+
+// SearchRequestList は全てのアカウントの申請一覧を検索し、アカウント名を付けて結果をまとめます。
+// accountを指定した場合はそのアカウントのみで検索します。
+func (m *MultiClient) SearchRequestList(account string, search RequestSearch) ([]AccountRequest, error) {
+	accounts, err := m.targets(account, anyVersion)
+	if err != nil {
+		return nil, err
+	}
+	requests := make([][]AccountRequest, len(accounts))
+
+	err = fanOut(accounts, anyVersion, func(i int, account *Account, con *Config) error {
+		result, err := con.SearchRequestList(search)
+		for _, info := range result {
+			requests[i] = append(requests[i], AccountRequest{Account: account.name(), RequestInfo: info})
+		}
+		return err
+	})
+
+	var all []AccountRequest
+	for i := range accounts {
+		all = append(all, requests[i]...)
+	}
+	return all, err
+}
+
+// GetResourceManagement は全てのアカウントの資源管理者情報を取得します。
+func (m *MultiClient) GetResourceManagement() ([]AccountResource, error) {
+	resources := make([]*AccountResource, len(m.Accounts))
+
+	err := fanOut(m.Accounts, anyVersion, func(i int, account *Account, con *Config) error {
+		info, _, err := con.GetResourceManagement()
+		if err != nil {
+			return err
+		}
+		resources[i] = &AccountResource{Account: account.name(), ResourceInfo: info}
+		return nil
+	})
+
+	var all []AccountResource
+	for _, resource := range resources {
+		if resource != nil {
+			all = append(all, *resource)
+		}
+	}
+	return all, err
+}
+
+// targets はaccountが空の場合は全てのアカウント、指定された場合はそのアカウントのみを返します。
+// 登録されていないアカウントや、指定されたアカウントにversionの証明書が無い場合はエラーを返します。
+// (全てのアカウントが対象の場合、証明書を持たないアカウントはfanOutで対象外になります)
+func (m *MultiClient) targets(account string, version int) ([]*Account, error) {
+	if strings.TrimSpace(account) == "" {
+		return m.Accounts, nil
+	}
+	target, err := m.Account(account)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = target.configFor(version); err != nil {
+		return nil, err
+	}
+	return []*Account{target}, nil
+}
+
+func tagHandles(account *Account, handles []JPNICHandleDetail) []AccountJPNICHandle {
+	var result []AccountJPNICHandle
+	for _, handle := range handles {
+		result = append(result, AccountJPNICHandle{Account: account.name(), JPNICHandleDetail: handle})
+	}
+	return result
+}
+
+// fanOut はversionに対応する証明書を持つアカウント毎にfnを並行して実行します。証明書を持たないアカウントは対象外です。
+// fnのindexはaccounts内の位置で、結果は呼び出し元がindex毎に保持します。失敗したアカウントはFanOutErrorにまとめて返します。
+func fanOut(accounts []*Account, version int, fn func(index int, account *Account, con *Config) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(accounts))
+
+	for i, account := range accounts {
+		con := account.config(version)
+		if con == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, account *Account, con *Config) {
+			defer wg.Done()
+			errs[i] = fn(i, account, con)
+		}(i, account, con)
+	}
+	wg.Wait()
+
+	var fanOutErr FanOutError
+	for i, err := range errs {
+		if err != nil {
+			fanOutErr = append(fanOutErr, &AccountError{Account: accounts[i].name(), Err: err})
+		}
+	}
+	if len(fanOutErr) != 0 {
+		return fanOutErr
+	}
+	return nil
+}
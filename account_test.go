@@ -0,0 +1,107 @@
+package jpnic
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMultiClientRouting(t *testing.T) {
+	v4 := &Config{PfxFilePath: "v4.p12"}
+	v6 := &Config{PfxFilePath: "v6.p12"}
+	other := &Config{PfxFilePath: "other-v4.p12"}
+
+	m := &MultiClient{Accounts: []*Account{
+		{Ryakusho: "HOMENOC", IPv4: v4, IPv6: v6},
+		{Name: "other", Ryakusho: "OTHER", IPv4: other},
+	}}
+
+	for _, test := range []struct {
+		ryakusho string
+		version  int
+		want     *Config
+	}{
+		{"HOMENOC", 4, v4},
+		{"homenoc", 6, v6},
+		{"HOMENOC", anyVersion, v4},
+		{"other", 4, other},
+		{"OTHER", anyVersion, other},
+	} {
+		con, err := m.ConfigFor(test.ryakusho, test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if con != test.want {
+			t.Errorf("%s v%d: %s", test.ryakusho, test.version, con.PfxFilePath)
+		}
+	}
+
+	if _, err := m.ConfigFor("OTHER", 6); err == nil {
+		t.Error("expected error for missing IPv6 certificate")
+	}
+	if _, err := m.ConfigFor("", 4); err == nil {
+		t.Error("expected error for empty ryakusho with multiple accounts")
+	}
+
+	result := m.Send(WebTransaction{Network: Network{Ryakusyo: "OTHER", IPAddress: "2001:db8::/48"}})
+	if result.Err == nil {
+		t.Error("expected error for IPv6 transaction without IPv6 certificate")
+	}
+
+	if targets, err := m.targets("other", 4); err != nil || len(targets) != 1 || targets[0].Name != "other" {
+		t.Errorf("targets: %+v %v", targets, err)
+	}
+	if targets, err := m.targets("", 6); err != nil || len(targets) != 2 {
+		t.Errorf("targets: %+v %v", targets, err)
+	}
+	// 登録されていないアカウントは全アカウントに送らずエラーにする
+	if targets, err := m.targets("UNKNOWN", 4); err == nil {
+		t.Errorf("targets: %+v", targets)
+	}
+	// 指定されたアカウントに証明書が無い場合は、空の結果ではなくConfigForと同じエラーにする
+	_, _, err := m.SearchIPv6("OTHER", SearchIPv6{})
+	if _, want := m.ConfigFor("OTHER", 6); err == nil || err.Error() != want.Error() {
+		t.Errorf("SearchIPv6: %v", err)
+	}
+	if _, _, err := m.SearchIPv4("UNKNOWN", SearchIPv4{}); err == nil {
+		t.Error("expected error for unknown account")
+	}
+	if _, err := m.GetIPUser("OTHER", 6, "/jpnic/user.do"); err == nil {
+		t.Error("expected error for missing IPv6 certificate")
+	}
+	if _, err := m.GetJPNICHandle("UNKNOWN", "YY38053JP"); err == nil {
+		t.Error("expected error for unknown account")
+	}
+	if _, err := m.RegisterHandle("", JPNICHandleInput{}); err == nil {
+		t.Error("expected error for empty account with multiple accounts")
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	accounts := []*Account{
+		{Ryakusho: "A", IPv4: &Config{}},
+		{Ryakusho: "B", IPv6: &Config{}},
+		{Ryakusho: "C", IPv4: &Config{}},
+	}
+
+	var calls int32
+	results := make([]string, len(accounts))
+	errFailed := errors.New("failed")
+	err := fanOut(accounts, 4, func(i int, account *Account, con *Config) error {
+		atomic.AddInt32(&calls, 1)
+		results[i] = account.Ryakusho
+		if account.Ryakusho == "C" {
+			return errFailed
+		}
+		return nil
+	})
+
+	if calls != 2 || results[0] != "A" || results[1] != "" || results[2] != "C" {
+		t.Fatalf("calls: %d, results: %v", calls, results)
+	}
+
+	var fanOutErr FanOutError
+	if !errors.As(err, &fanOutErr) || len(fanOutErr) != 1 || fanOutErr[0].Account != "C" || !errors.Is(fanOutErr[0], errFailed) {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package jpnic
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ParseError はJPNICのページを解析できなかった場合のエラーです。errors.Asで取り出してください。
+// CaptureDirを設定している場合、解析できなかったページを保存し、Pathに保存先を設定します。
+type ParseError struct {
+	// 処理名(SearchIPv4等)
+	Operation string
+	// 処理の段階(login, menu, form.fetch, form.submit, parse等)
+	Step string
+	// ページのURL(セッションID・TOKENはマスクされます)
+	URL string
+	// 保存したHTML(UTF-8)のパス(保存していない場合は空)
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + " (保存先: " + e.Path + ")"
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// operationInfo は公開APIの処理の情報で、startOperationでcontextに保存します。
+type operationInfo struct {
+	name       string
+	captureDir string
+	logger     Logger
+}
+
+type operationKey struct{}
+
+func operationFrom(ctx context.Context) operationInfo {
+	if ctx != nil {
+		if op, ok := ctx.Value(operationKey{}).(operationInfo); ok {
+			return op
+		}
+	}
+	return operationInfo{logger: nopLogger{}}
+}
+
+// newParseError はParseErrorを返します。CaptureDirが設定されている場合はページを保存します。
+func newParseError(ctx context.Context, step, pageURL, html string, err error) error {
+	op := operationFrom(ctx)
+	parseErr := &ParseError{
+		Operation: op.name,
+		Step:      step,
+		URL:       (&redactLogger{}).redact(pageURL),
+		Err:       err,
+	}
+
+	if op.captureDir != "" {
+		path, captureErr := capturePage(op.captureDir, parseErr, html)
+		if captureErr != nil {
+			op.logger.Log(LogWarn, "ページを保存できませんでした", "operation", op.name, "step", step, "error", captureErr)
+		} else {
+			parseErr.Path = path
+		}
+	}
+	return parseErr
+}
+
+// captureEmpty は結果が空だった場合にページを保存し、ログに出力します。(エラーにはしません)
+func captureEmpty(ctx context.Context, step, pageURL, html string) {
+	op := operationFrom(ctx)
+	if op.captureDir == "" {
+		return
+	}
+
+	path, err := capturePage(op.captureDir, &ParseError{Operation: op.name, Step: step, URL: (&redactLogger{}).redact(pageURL)}, html)
+	if err != nil {
+		op.logger.Log(LogWarn, "ページを保存できませんでした", "operation", op.name, "step", step, "error", err)
+		return
+	}
+	op.logger.Log(LogWarn, "結果が空のためページを保存しました", "operation", op.name, "step", step, "path", path)
+}
+
+// capturedPage は保存したページの情報(HTMLと同じ名前の.json)です。
+type capturedPage struct {
+	Operation string    `json:"operation"`
+	Step      string    `json:"step"`
+	URL       string    `json:"url"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+	HTML      string    `json:"html"`
+}
+
+// capturePage はUTF-8に変換済みのHTMLをdirに保存し、HTMLのパスを返します。
+// ページには個人情報が含まれるため、パーミッションは0600(ディレクトリは0700)です。
+func capturePage(dir string, e *ParseError, html string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	name := now.Format("20060102-150405.000000")
+	for _, part := range []string{e.Operation, e.Step} {
+		if part != "" {
+			name += "-" + strings.NewReplacer("/", "_", "\\", "_", " ", "_").Replace(part)
+		}
+	}
+	path := filepath.Join(dir, name+".html")
+
+	// <meta charset="Shift_JIS">等があってもブラウザで開けるようにBOMを付ける
+	if err := ioutil.WriteFile(path, []byte("\xef\xbb\xbf"+html), 0600); err != nil {
+		return "", err
+	}
+
+	page := capturedPage{Operation: e.Operation, Step: e.Step, URL: e.URL, Time: now, HTML: filepath.Base(path)}
+	if e.Err != nil {
+		page.Error = e.Err.Error()
+	}
+	data, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, name+".json"), data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,165 @@
+package jpnic
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newMenuCassette はログインとメニューの取得の後に、pagesを返す再生用のCassetteを作成します。
+func newMenuCassette(t *testing.T, menu string, pages ...Interaction) *Cassette {
+	header := http.Header{"Content-Type": {"text/html; charset=Shift_JIS"}}
+	cassette := &Cassette{Version: cassetteVersion, replay: true}
+	cassette.Interactions = append([]Interaction{
+		{
+			Request: CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/certmemberlogin.do"},
+			Response: CassetteResponse{StatusCode: 200, Header: header,
+				Body: toShiftJISTest(t, `<meta http-equiv="refresh" content="0;URL=menu.do">`)},
+		},
+		{
+			Request: CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/menu.do"},
+			Response: CassetteResponse{StatusCode: 200, Header: header,
+				Body: toShiftJISTest(t, `<table><tr><td><table><tr><td><a href="form.do;jsessionid=ABCDEF">`+menu+`</a></td></tr></table></td></tr></table>`)},
+		},
+	}, pages...)
+	for i := 2; i < len(cassette.Interactions); i++ {
+		cassette.Interactions[i].Response.Header = header
+		cassette.Interactions[i].Response.Body = toShiftJISTest(t, string(cassette.Interactions[i].Response.Body))
+	}
+	return cassette
+}
+
+func TestParseErrorCapture(t *testing.T) {
+	const page = `<html><body><p>ただいま混み合っています</p></body></html>`
+	dir := filepath.Join(t.TempDir(), "capture")
+
+	con := Config{
+		CaptureDir: dir,
+		Cassette: newMenuCassette(t, "登録情報検索(IPv4)", Interaction{
+			Request:  CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/form.do"},
+			Response: CassetteResponse{StatusCode: 200, Body: []byte(page)},
+		}),
+	}
+	_, _, err := con.SearchIPv4(SearchIPv4{})
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected ParseError: %v", err)
+	}
+	if parseErr.Operation != "SearchIPv4" || parseErr.Step != "form.fetch" || parseErr.URL != baseURL+"/jpnic/form.do;jsessionid="+redacted {
+		t.Fatalf("%+v", parseErr)
+	}
+	if parseErr.Path == "" || !strings.Contains(err.Error(), parseErr.Path) {
+		t.Fatalf("path: %q", err.Error())
+	}
+
+	html, err := ioutil.ReadFile(parseErr.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(html) != "\xef\xbb\xbf"+page {
+		t.Errorf("html: %q", html)
+	}
+
+	data, err := ioutil.ReadFile(strings.TrimSuffix(parseErr.Path, ".html") + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var captured capturedPage
+	if err = json.Unmarshal(data, &captured); err != nil {
+		t.Fatal(err)
+	}
+	if captured.Operation != "SearchIPv4" || captured.Step != "form.fetch" || captured.Error != "submit URLが取得できませんでした" {
+		t.Errorf("%+v", captured)
+	}
+
+	// CaptureDirが無い場合は保存しない
+	con = Config{
+		Cassette: newMenuCassette(t, "登録情報検索(IPv4)", Interaction{
+			Request:  CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/form.do"},
+			Response: CassetteResponse{StatusCode: 200, Body: []byte(page)},
+		}),
+	}
+	_, _, err = con.SearchIPv4(SearchIPv4{})
+	if !errors.As(err, &parseErr) || parseErr.Path != "" || err.Error() != "submit URLが取得できませんでした" {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestCaptureEmptyRequestList(t *testing.T) {
+	dir := t.TempDir()
+	con := Config{
+		CaptureDir: dir,
+		Cassette: newMenuCassette(t, "申請一覧",
+			Interaction{
+				Request: CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/form.do"},
+				Response: CassetteResponse{StatusCode: 200,
+					Body: []byte(`<form action="/jpnic/list.do"><input name="destdisp" value="D1"></form>`)},
+			},
+			Interaction{
+				Request:  CassetteRequest{Method: "POST", URL: baseURL + "/jpnic/list.do"},
+				Response: CassetteResponse{StatusCode: 200, Body: []byte(`<p>検索条件を入力してください</p>`)},
+			},
+		),
+	}
+
+	infos, err := con.GetRequestList("")
+	if err != nil || len(infos) != 0 {
+		t.Fatalf("%v %v", infos, err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*-SearchRequestList-parse.html"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("%v %v", files, err)
+	}
+}
+
+// searchIPv4Rows は見出しの行と1行のデータを持つIPv4の検索結果のページを返します。
+func searchIPv4Rows(detailLink string) string {
+	row := func(link string, values ...string) string {
+		html := `<tr><td class="dataRow_mnt04"><a href="` + link + `">192.0.2.0/24</a></td>`
+		for _, value := range values {
+			html += `<td class="dataRow_mnt04">` + value + `</td>`
+		}
+		return html + `</tr>`
+	}
+	return `<table>` +
+		row("", "サイズ", "ネットワーク名", "割当年月日", "返却年月日", "組織名", "略称", "受付番号", "審議番号", "種別", "区分") +
+		row(detailLink, "256", "EXAMPLE-NET", "2021/10/01", "", "ホームNOC", "HOMENOC", "1", "2", "割り当て", "1") +
+		`</table>`
+}
+
+func TestSearchIPv4DetailParseError(t *testing.T) {
+	con := Config{
+		CaptureDir: t.TempDir(),
+		Cassette: newMenuCassette(t, "登録情報検索(IPv4)",
+			Interaction{
+				Request: CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/form.do"},
+				Response: CassetteResponse{StatusCode: 200,
+					Body: []byte(`<form action="/jpnic/search.do"><input name="destdisp" value="D1"></form>`)},
+			},
+			Interaction{
+				Request:  CassetteRequest{Method: "POST", URL: baseURL + "/jpnic/search.do"},
+				Response: CassetteResponse{StatusCode: 200, Body: []byte(searchIPv4Rows("/jpnic/detail.do"))},
+			},
+			Interaction{
+				Request:  CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/detail.do"},
+				Response: CassetteResponse{StatusCode: 200, Body: []byte(`<p>ただいま混み合っています</p>`)},
+			},
+		),
+	}
+
+	// 詳細情報を解析できなかった場合は、結果を途中までにせずParseErrorを返す
+	infos, _, err := con.SearchIPv4(SearchIPv4{IsDetail: true})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected ParseError: %v (%d rows)", err, len(infos))
+	}
+	if parseErr.Operation != "SearchIPv4" || parseErr.Step != "detail.fetch" || parseErr.Path == "" {
+		t.Fatalf("%+v", parseErr)
+	}
+}
@@ -0,0 +1,287 @@
+package jpnic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cassette はHTTPのリクエスト・レスポンスを記録し、後から通信せずに再生します。
+// NewCassetteで作成した場合は記録、LoadCassetteで読み込んだ場合は再生を行います。
+// パスワード・TOKEN・セッションID・Cookie・メールアドレス・電話番号はマスクして記録します。
+type Cassette struct {
+	Version      int           `json:"version"`
+	RecordedAt   time.Time     `json:"recorded_at"`
+	Interactions []Interaction `json:"interactions"`
+
+	mu     sync.Mutex
+	path   string
+	replay bool
+	next   int
+}
+
+// Interaction は1回のリクエストとそのレスポンスです。本文はJPNICが送信した文字コード(Shift_JIS等)のまま保存します。
+type Interaction struct {
+	// initAccessで開いたメニュー名
+	Menu     string           `json:"menu,omitempty"`
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+type CassetteRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+type CassetteResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+const cassetteVersion = 1
+
+// NewCassette は記録用のCassetteを返します。記録した内容はSaveでpathに保存します。
+func NewCassette(path string) *Cassette {
+	return &Cassette{Version: cassetteVersion, RecordedAt: time.Now(), path: path}
+}
+
+// LoadCassette はpathに保存したCassetteを再生用に読み込みます。
+// 再生時は証明書を読み込まず、記録した順にレスポンスを返します。(メソッドとパスが異なる場合はエラー)
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cassette{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if c.Version != cassetteVersion {
+		return nil, fmt.Errorf("対応していないカセットのバージョンです: %d", c.Version)
+	}
+	c.path = path
+	c.replay = true
+	return c, nil
+}
+
+// Save は記録した内容をJSONで保存します。
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, data, 0600)
+}
+
+// cassetteTransport はbaseがある場合は記録、無い場合はCassetteの再生を行います。
+type cassetteTransport struct {
+	cassette *Cassette
+	base     http.RoundTripper
+	scrub    *redactLogger
+	menu     string
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.base == nil {
+		return t.cassette.play(req)
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Menu: t.menu,
+		Request: CassetteRequest{
+			Method: req.Method,
+			URL:    t.scrub.redact(req.URL.String()),
+			Header: t.scrubHeader(req.Header),
+			Body:   t.scrubForm(reqBody),
+		},
+		Response: CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Header:     t.scrubHeader(resp.Header),
+		},
+	}
+	interaction.Response.Body, interaction.Response.Header = t.scrubHTML(respBody, interaction.Response.Header)
+
+	t.cassette.mu.Lock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	t.cassette.mu.Unlock()
+
+	return resp, nil
+}
+
+// play は次に記録されているレスポンスを返します。
+func (c *Cassette) play(req *http.Request) (*http.Response, error) {
+	// 通信する場合と同じく、キャンセル済みのリクエストは送信しない
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next >= len(c.Interactions) {
+		return nil, fmt.Errorf("カセットに記録されていないリクエストです: %s %s", req.Method, tracePath(req.URL))
+	}
+	interaction := c.Interactions[c.next]
+
+	recorded, err := url.Parse(interaction.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+	if interaction.Request.Method != req.Method || tracePath(recorded) != tracePath(req.URL) {
+		return nil, fmt.Errorf("カセットの%d番目のリクエスト(%s %s)と一致しません: %s %s", c.next+1,
+			interaction.Request.Method, tracePath(recorded), req.Method, tracePath(req.URL))
+	}
+	c.next++
+
+	header := interaction.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(interaction.Response.Body)))
+
+	return &http.Response{
+		Status:        strconv.Itoa(interaction.Response.StatusCode) + " " + http.StatusText(interaction.Response.StatusCode),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+var (
+	// Cookie: a=1; b=2 の値
+	cookieValueRegexp = regexp.MustCompile(`(^|;\s*)([^=;\s]+)=[^;]*`)
+	// Set-Cookie: a=1; Path=/ の値(属性は残す)
+	setCookieValueRegexp = regexp.MustCompile(`^([^=;\s]+)=[^;]*`)
+	// フォーム(a=1&b=2)・Webトランザクション(A=1\nB=2)の項目
+	formFieldRegexp = regexp.MustCompile(`([^&\r\n=]+)=([^&\r\n]*)`)
+)
+
+// scrubHeader はCookie・認証情報の値をマスクしたヘッダを返します。
+func (t *cassetteTransport) scrubHeader(header http.Header) http.Header {
+	scrubbed := make(http.Header, len(header))
+	for key, values := range header {
+		for _, value := range values {
+			switch http.CanonicalHeaderKey(key) {
+			case "Cookie":
+				value = cookieValueRegexp.ReplaceAllString(value, "${1}${2}="+redacted)
+			case "Set-Cookie":
+				value = setCookieValueRegexp.ReplaceAllString(value, "${1}="+redacted)
+			case "Authorization", "Proxy-Authorization":
+				value = redacted
+			default:
+				value = t.scrub.redact(value)
+			}
+			scrubbed.Add(key, value)
+		}
+	}
+	return scrubbed
+}
+
+// scrubForm はリクエストの本文の項目のうち、パスワード等の値とメールアドレス・電話番号をマスクします。
+// 本文はShift_JIS(CP932)のため、値をUTF-8に変換してからマスクし、変更があった場合のみ元の形式に戻します。
+func (t *cassetteTransport) scrubForm(body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	return formFieldRegexp.ReplaceAllFunc(body, func(field []byte) []byte {
+		match := formFieldRegexp.FindSubmatch(field)
+		key, value := match[1], match[2]
+		if sensitiveKey(string(key)) {
+			return []byte(string(key) + "=" + redacted)
+		}
+
+		raw := string(value)
+		unescaped, err := url.QueryUnescape(raw)
+		escaped := err == nil && unescaped != raw
+		if !escaped {
+			unescaped = raw
+		}
+		str, err := japanese.ShiftJIS.NewDecoder().String(unescaped)
+		if err != nil {
+			return field
+		}
+		scrubbed := t.scrub.redact(str)
+		if scrubbed == str {
+			return field
+		}
+		scrubbed, err = japanese.ShiftJIS.NewEncoder().String(scrubbed)
+		if err != nil {
+			return field
+		}
+		if escaped {
+			scrubbed = url.QueryEscape(scrubbed)
+		}
+		return []byte(string(key) + "=" + scrubbed)
+	})
+}
+
+// scrubHTML はレスポンスの本文をマスクし、元の文字コードに戻します。
+// 元の文字コードに戻せない場合はUTF-8で保存し、Content-Typeのcharsetを変更します。
+func (t *cassetteTransport) scrubHTML(body []byte, header http.Header) ([]byte, http.Header) {
+	if len(body) == 0 {
+		return body, header
+	}
+
+	enc := htmlEncoding(body, header.Get("Content-Type"), japanese.ShiftJIS)
+	str, err := enc.NewDecoder().String(string(body))
+	if err != nil {
+		return []byte(t.scrub.redact(string(body))), header
+	}
+	scrubbed := t.scrub.redact(str)
+	if scrubbed == str {
+		return body, header
+	}
+
+	if enc != encoding.Nop {
+		if encoded, err := enc.NewEncoder().String(scrubbed); err == nil {
+			return []byte(encoded), header
+		}
+		header.Set("Content-Type", "text/html; charset=UTF-8")
+	}
+	return []byte(scrubbed), header
+}
@@ -0,0 +1,173 @@
+package jpnic
+
+import (
+	"golang.org/x/text/encoding/japanese"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func toShiftJISTest(t *testing.T, str string) []byte {
+	b, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(str))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	page := `<html><body><form action="/jpnic/entryinfo.do;jsessionid=ABCDEF">` +
+		`<input type="hidden" name="org.apache.struts.taglib.html.TOKEN" value="0123456789abcdef">` +
+		`申請者①～ 担当者 user@example.ad.jp</form></body></html>`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "server-session", Path: "/"})
+		w.Header().Set("Content-Type", "text/html; charset=Shift_JIS")
+		w.Write(toShiftJISTest(t, page))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	con := Config{
+		CertFilePath: "testdata/client.pem",
+		KeyFilePath:  "testdata/client.key",
+		KeyPass:      "key-password",
+		Cassette:     NewCassette(path),
+	}
+
+	jar, _ := cookiejar.New(nil)
+	client, err := con.newHTTPClient(jar, "IPv6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := request{
+		Client:      client,
+		URL:         srv.URL + "/jpnic/entryinfo.do;jsessionid=ABCDEF?org.apache.struts.taglib.html.TOKEN=0123456789abcdef",
+		Body:        "PSWD=key-password&name=%82%A0&mail=user%40example.ad.jp",
+		UserAgent:   userAgent,
+		ContentType: contentType,
+	}
+	resp, err := r.post()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 記録中も呼び出し元にはマスク前の応答を返す
+	body, _, err := readHTML(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != page {
+		t.Fatalf("body: %q", body)
+	}
+
+	// 2回目のリクエストはCookieを送信する
+	r.Body = ""
+	resp, err = r.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if err = con.Cassette.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"ABCDEF", "0123456789abcdef", "key-password", "server-session", "user@example", "user%40example"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("%q is recorded", secret)
+		}
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != 2 {
+		t.Fatalf("interactions: %d", len(cassette.Interactions))
+	}
+	if got := string(cassette.Interactions[0].Request.Body); got != "PSWD=[REDACTED]&name=%82%A0&mail=%5BREDACTED%5D" {
+		t.Errorf("request body: %q", got)
+	}
+	if got := cassette.Interactions[1].Request.Header.Get("Cookie"); got != "JSESSIONID="+redacted {
+		t.Errorf("cookie: %q", got)
+	}
+
+	// 再生(証明書無し・通信無し)
+	srv.Close()
+	replay := Config{Cassette: cassette}
+	jar, _ = cookiejar.New(nil)
+	client, err = replay.newHTTPClient(jar, "IPv6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Client = client
+	r.Body = "PSWD=other"
+	resp, err = r.post()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _, err = readHTML(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"申請者①～", `value="` + redacted + `"`, "担当者 " + redacted} {
+		if !strings.Contains(body, want) {
+			t.Errorf("%q not in %q", want, body)
+		}
+	}
+
+	// 記録と異なるリクエスト
+	if _, err = r.post(); err == nil {
+		t.Fatal("expected mismatch error")
+	}
+}
+
+func TestCassetteReplayResourceManagement(t *testing.T) {
+	header := http.Header{"Content-Type": {"text/html; charset=Shift_JIS"}}
+	cassette := NewCassette(filepath.Join(t.TempDir(), "cassette.json"))
+	cassette.Interactions = []Interaction{
+		{
+			Request: CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/certmemberlogin.do"},
+			Response: CassetteResponse{StatusCode: 200, Header: header,
+				Body: toShiftJISTest(t, `<html><head><meta http-equiv="refresh" content="0;URL=menu.do"></head></html>`)},
+		},
+		{
+			Request: CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/menu.do"},
+			Response: CassetteResponse{StatusCode: 200, Header: header,
+				Body: toShiftJISTest(t, `<table><tr><td><table><tr><td><a href="resource.do">資源管理者情報</a></td></tr></table></td></tr></table>`)},
+		},
+		{
+			Request: CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/resource.do"},
+			Response: CassetteResponse{StatusCode: 200, Header: header,
+				Body: toShiftJISTest(t, `<table><tr><td><table><tr><td><table><tr><td><table>`+
+					`<tr><td>資源管理者番号</td><td>A12345</td></tr>`+
+					`<tr><td>管理組織名</td><td>髙橋①株式会社</td></tr>`+
+					`</table></td></tr></table></td></tr></table></td></tr></table>`)},
+		},
+	}
+	if err := cassette.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadCassette(cassette.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	con := Config{Cassette: loaded}
+	info, _, err := con.GetResourceManagement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ResourceManagerInfo.ResourceManagerNo != "A12345" || info.ResourceManagerInfo.Org != "髙橋①株式会社" {
+		t.Fatalf("%+v", info.ResourceManagerInfo)
+	}
+}
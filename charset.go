@@ -0,0 +1,87 @@
+package jpnic
+
+import (
+	"bytes"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// lookupCharset は文字コード名に対応するエンコーディングを返します。
+// Shift_JISはCP932(Windows-31J)として扱います。(JPNICのフォームはCP932の①や～等を使うため)
+func lookupCharset(charset string) (encoding.Encoding, bool) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return encoding.Nop, true
+	case "shift_jis", "shift-jis", "sjis", "x-sjis", "ms_kanji", "csshiftjis", "windows-31j", "cp932", "ms932", "x-ms-cp932":
+		return japanese.ShiftJIS, true
+	case "euc-jp", "x-euc-jp", "eucjp", "cseucpkdfmtjapanese":
+		return japanese.EUCJP, true
+	case "iso-2022-jp", "csiso2022jp":
+		return japanese.ISO2022JP, true
+	}
+	return nil, false
+}
+
+// <meta charset="..."> と <meta http-equiv="Content-Type" content="text/html; charset=..."> のどちらにも一致します。
+var metaCharsetRegexp = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_.:\-]+)`)
+
+// htmlEncoding はContent-Typeのcharset、BOM、<meta>のcharsetの順で文字コードを判定します。判定できない場合はfallbackを返します。
+func htmlEncoding(raw []byte, contentType string, fallback encoding.Encoding) encoding.Encoding {
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if enc, ok := lookupCharset(params["charset"]); ok && params["charset"] != "" {
+			return enc
+		}
+	}
+
+	if bytes.HasPrefix(raw, []byte("\xef\xbb\xbf")) {
+		return encoding.Nop
+	}
+
+	head := raw
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	if match := metaCharsetRegexp.FindSubmatch(head); match != nil {
+		if enc, ok := lookupCharset(string(match[1])); ok {
+			return enc
+		}
+	}
+
+	return fallback
+}
+
+// decodeHTML はhtmlEncodingで判定した文字コードでUTF-8に変換します。
+func decodeHTML(raw []byte, contentType string, fallback encoding.Encoding) (string, []byte, error) {
+	strByte, err := htmlEncoding(raw, contentType, fallback).NewDecoder().Bytes(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	strByte = bytes.TrimPrefix(strByte, []byte("\xef\xbb\xbf"))
+	return string(strByte), strByte, nil
+}
+
+// readHTML は応答の本文を読み込み、UTF-8に変換します。文字コードが判定できない場合はShift_JIS(CP932)とします。
+func readHTML(resp *http.Response) (string, []byte, error) {
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	return decodeHTML(raw, resp.Header.Get("Content-Type"), japanese.ShiftJIS)
+}
+
+// cp932Replacer はCP932に無い(JIS X 0208の対応表での)文字を、CP932で同じ見た目の文字に置き換えます。
+// Macや一部の入力ではこちらの文字が使われ、そのままではShift_JIS(CP932)に変換できないためです。
+var cp932Replacer = strings.NewReplacer(
+	"〜", "～", // 〜 WAVE DASH → ～ FULLWIDTH TILDE
+	"‖", "∥", // ‖ DOUBLE VERTICAL LINE → ∥ PARALLEL TO
+	"−", "－", // − MINUS SIGN → － FULLWIDTH HYPHEN-MINUS
+	"—", "―", // — EM DASH → ― HORIZONTAL BAR
+	"¢", "￠", // ¢ → ￠
+	"£", "￡", // £ → ￡
+	"¬", "￢", // ¬ → ￢
+)
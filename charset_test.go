@@ -0,0 +1,78 @@
+package jpnic
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"testing"
+)
+
+func TestDecodeHTML(t *testing.T) {
+	const text = "申請者①～ⅰ髙"
+
+	encode := func(enc encoding.Encoding, s string) []byte {
+		b, err := enc.NewEncoder().Bytes([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	for _, tt := range []struct {
+		name        string
+		raw         []byte
+		contentType string
+		want        string
+	}{
+		{
+			name:        "Content-Type(Windows-31J)",
+			raw:         encode(japanese.ShiftJIS, "<p>"+text+"</p>"),
+			contentType: "text/html; charset=Windows-31J",
+			want:        "<p>" + text + "</p>",
+		},
+		{
+			name: "meta charset(EUC-JP)",
+			raw:  encode(japanese.EUCJP, `<html><head><meta charset="EUC-JP"></head><body>申請者</body></html>`),
+			want: `<html><head><meta charset="EUC-JP"></head><body>申請者</body></html>`,
+		},
+		{
+			name: "http-equiv(UTF-8)",
+			raw:  []byte(`<meta http-equiv="Content-Type" content="text/html; charset=UTF-8"><p>` + text + `</p>`),
+			want: `<meta http-equiv="Content-Type" content="text/html; charset=UTF-8"><p>` + text + `</p>`,
+		},
+		{
+			name:        "Content-Typeを優先(ISO-2022-JP)",
+			raw:         encode(japanese.ISO2022JP, `<meta charset="Shift_JIS">申請者`),
+			contentType: "text/html; charset=ISO-2022-JP",
+			want:        `<meta charset="Shift_JIS">申請者`,
+		},
+		{
+			name: "指定無し(CP932)",
+			raw:  encode(japanese.ShiftJIS, "<p>"+text+"</p>"),
+			want: "<p>" + text + "</p>",
+		},
+		{
+			name: "BOM",
+			raw:  []byte("\xef\xbb\xbf<p>" + text + "</p>"),
+			want: "<p>" + text + "</p>",
+		},
+	} {
+		body, _, err := decodeHTML(tt.raw, tt.contentType, japanese.ShiftJIS)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if body != tt.want {
+			t.Errorf("%s: %q", tt.name, body)
+		}
+	}
+}
+
+func TestToShiftJIS(t *testing.T) {
+	// JIS X 0208の対応表の文字(〜 −)もCP932の文字として送信する
+	_, b, err := toShiftJIS("10〜20−①")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "10\x81\x6020\x81\x7c\x87\x40"; string(b) != want {
+		t.Fatalf("% x", b)
+	}
+}
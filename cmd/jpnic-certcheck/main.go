@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/homenoc/jpnic-go"
+	"log"
+	"os"
+	"strings"
+)
+
+// 終了コード: 0=正常, 1=エラー(期限切れ等), 2=警告(有効期限が近い)
+func main() {
+	pfx := flag.String("pfx", "", ".p12 file path")
+	cert := flag.String("cert", "", "PEM certificate file path (instead of -pfx)")
+	key := flag.String("key", "", "PEM private key file path (instead of -pfx)")
+	ca := flag.String("ca", "", "CA certificate file path")
+	days := flag.Int("days", 30, "warn if the certificate expires within this number of days")
+	jsonOutput := flag.Bool("json", false, "output as JSON")
+	flag.Parse()
+
+	if *pfx == "" && *cert == "" {
+		log.Fatal("-pfx or -cert is required")
+	}
+
+	con := jpnic.Config{
+		PfxFilePath:  *pfx,
+		PfxPass:      os.Getenv("JPNIC_PFX_PASS"),
+		CertFilePath: *cert,
+		KeyFilePath:  *key,
+		KeyPass:      os.Getenv("JPNIC_KEY_PASS"),
+		CAFilePath:   *ca,
+	}
+
+	report, err := con.CheckCredentials(*days)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		const layout = "2006/01/02 15:04:05"
+		fmt.Printf("Subject:    %s\n", report.Subject)
+		fmt.Printf("Issuer:     %s\n", report.Issuer)
+		fmt.Printf("Serial:     %s\n", report.Serial)
+		fmt.Printf("Not Before: %s\n", report.NotBefore.Local().Format(layout))
+		fmt.Printf("Not After:  %s (残り%d日)\n", report.NotAfter.Local().Format(layout), report.DaysLeft)
+		fmt.Printf("Key Match:  %t\n", report.KeyMatch)
+		fmt.Printf("Chain:      %s\n", strings.Join(report.Chain, " -> "))
+		for _, warning := range report.Warnings {
+			fmt.Printf("[WARN] %s\n", warning)
+		}
+		for _, e := range report.Errors {
+			fmt.Printf("[ERROR] %s\n", e)
+		}
+	}
+
+	switch {
+	case !report.OK():
+		os.Exit(1)
+	case len(report.Warnings) != 0:
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	storeDir := flag.String("store", "", "snapshot store directory")
+	from := flag.String("from", "", "compare from (RFC 3339, default: the snapshot before the latest)")
+	to := flag.String("to", "", "compare to (RFC 3339, default: the latest)")
+	asJSON := flag.Bool("json", false, "output as JSON")
+	flag.Parse()
+
+	if *storeDir == "" {
+		log.Fatal("-store is required")
+	}
+	store := &snapshot.FileStore{Dir: *storeDir}
+
+	times, err := store.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var fromTime, toTime time.Time
+	if len(times) >= 2 {
+		fromTime, toTime = times[len(times)-2], times[len(times)-1]
+	}
+	if *from != "" {
+		if fromTime, err = time.Parse(time.RFC3339, *from); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *to != "" {
+		if toTime, err = time.Parse(time.RFC3339, *to); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if fromTime.IsZero() || toTime.IsZero() {
+		log.Fatal("at least 2 snapshots are required")
+	}
+
+	oldSnap, err := store.At(fromTime)
+	if err != nil {
+		log.Fatal(err)
+	}
+	newSnap, err := store.At(toTime)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report := snapshot.Diff(oldSnap, newSnap)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	fmt.Print(report)
+}
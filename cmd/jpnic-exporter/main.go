@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/homenoc/jpnic-go"
+	"github.com/homenoc/jpnic-go/exporter"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9642", "listen address")
+	pfxV4 := flag.String("pfx-v4", "", "IPv4 .p12 file path")
+	pfxV6 := flag.String("pfx-v6", "", "IPv6 .p12 file path")
+	ca := flag.String("ca", "", "CA certificate file path")
+	interval := flag.Duration("interval", time.Hour, "scrape interval")
+	warnDays := flag.Int("warn-days", 30, "days before client certificate expiry to report as expiring")
+	flag.Parse()
+
+	e := &exporter.Exporter{Interval: *interval, WarnDays: *warnDays}
+	if *pfxV4 != "" {
+		e.IPv4 = &jpnic.Config{
+			PfxFilePath: *pfxV4,
+			PfxPass:     os.Getenv("JPNIC_PFX_PASS"),
+			CAFilePath:  *ca,
+		}
+	}
+	if *pfxV6 != "" {
+		e.IPv6 = &jpnic.Config{
+			PfxFilePath: *pfxV6,
+			PfxPass:     os.Getenv("JPNIC_PFX_PASS"),
+			CAFilePath:  *ca,
+		}
+	}
+	if e.IPv4 == nil && e.IPv6 == nil {
+		log.Fatal("-pfx-v4 or -pfx-v6 is required")
+	}
+
+	go e.Run(context.Background())
+
+	http.Handle("/metrics", e)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"github.com/homenoc/jpnic-go/rdap"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	data := flag.String("data", "snapshot.json", "snapshot file path (created by jpnic-sync)")
+	baseURL := flag.String("base-url", "", "base URL used for self links")
+	flag.Parse()
+
+	h := &rdap.Handler{BaseURL: *baseURL}
+
+	snap, err := snapshot.Load(*data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	h.SetSnapshot(snap)
+
+	// SIGHUPでデータを再読み込み
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			snap, err := snapshot.Load(*data)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			h.SetSnapshot(snap)
+		}
+	}()
+
+	log.Fatal(http.ListenAndServe(*addr, h))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"github.com/homenoc/jpnic-go"
+	"github.com/homenoc/jpnic-go/server"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	url := flag.String("url", "https://iphostmaster.nic.ad.jp/webtrans/WebRegisterCtl", "Web Transaction URL")
+	pfx := flag.String("pfx", "", ".p12 file path")
+	ca := flag.String("ca", "", "CA certificate file path")
+	flag.Parse()
+
+	// パスフレーズとトークンはコマンドライン引数に残らないよう環境変数から取得する
+	tokens := strings.Split(os.Getenv("JPNIC_API_TOKENS"), ",")
+	if os.Getenv("JPNIC_API_TOKENS") == "" {
+		log.Fatal("JPNIC_API_TOKENS is not set")
+	}
+
+	con := &jpnic.Config{
+		URL:         *url,
+		PfxFilePath: *pfx,
+		PfxPass:     os.Getenv("JPNIC_PFX_PASS"),
+		CAFilePath:  *ca,
+	}
+
+	log.Fatal(http.ListenAndServe(*addr, server.New(con, tokens...)))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/homenoc/jpnic-go"
+	"github.com/homenoc/jpnic-go/notify"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	pfxV4 := flag.String("pfx-v4", "", "IPv4 .p12 file path")
+	pfxV6 := flag.String("pfx-v6", "", "IPv6 .p12 file path")
+	ca := flag.String("ca", "", "CA certificate file path")
+	out := flag.String("out", "snapshot.json", "output file path (empty to skip)")
+	storeDir := flag.String("store", "", "directory to keep timestamped snapshots")
+	webhook := flag.String("webhook", "", "webhook URL notified of changes since the latest stored snapshot (requires -store)")
+	deadLetter := flag.String("dead-letter", "", "file to append undeliverable webhook events")
+	thresholds := flag.String("threshold", "", "comma separated utilization ratio (%) thresholds")
+	flag.Parse()
+
+	if *webhook != "" && *storeDir == "" {
+		log.Fatal("-webhook requires -store")
+	}
+
+	var ratios []float64
+	for _, str := range strings.Split(*thresholds, ",") {
+		if str = strings.TrimSpace(str); str == "" {
+			continue
+		}
+		ratio, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			log.Fatalf("invalid threshold: %s", str)
+		}
+		ratios = append(ratios, ratio)
+	}
+
+	var v4, v6 *jpnic.Config
+	if *pfxV4 != "" {
+		v4 = &jpnic.Config{
+			PfxFilePath: *pfxV4,
+			PfxPass:     os.Getenv("JPNIC_PFX_PASS"),
+			CAFilePath:  *ca,
+		}
+	}
+	if *pfxV6 != "" {
+		v6 = &jpnic.Config{
+			PfxFilePath: *pfxV6,
+			PfxPass:     os.Getenv("JPNIC_PFX_PASS"),
+			CAFilePath:  *ca,
+		}
+	}
+	if v4 == nil && v6 == nil {
+		log.Fatal("-pfx-v4 or -pfx-v6 is required")
+	}
+
+	snap, err := snapshot.Fetch(v4, v6)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *out != "" {
+		if err = snap.Save(*out); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *storeDir != "" {
+		store, err := snapshot.NewFileStore(*storeDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		prev, err := store.Latest()
+		if err != nil && !errors.Is(err, snapshot.ErrNotFound) {
+			log.Fatal(err)
+		}
+
+		if err = store.Save(snap); err != nil {
+			log.Fatal(err)
+		}
+
+		if *webhook != "" && prev != nil {
+			n := &notify.Notifier{Webhooks: []*notify.Webhook{{
+				URL:            *webhook,
+				Secret:         []byte(os.Getenv("JPNIC_WEBHOOK_SECRET")),
+				DeadLetterPath: *deadLetter,
+			}}, Thresholds: ratios}
+
+			if err = n.Notify(context.Background(), n.SnapshotEvents(prev, snap)...); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
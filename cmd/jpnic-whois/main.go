@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"github.com/homenoc/jpnic-go/whois"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	addr := flag.String("addr", ":43", "listen address")
+	data := flag.String("data", "snapshot.json", "snapshot file path (created by jpnic-sync)")
+	redact := flag.String("redact", "name,email,phone,fax", "comma separated fields to redact (name,email,phone,fax,notify)")
+	flag.Parse()
+
+	s := &whois.Server{}
+	for _, field := range strings.Split(*redact, ",") {
+		switch strings.TrimSpace(field) {
+		case "name":
+			s.Policy.Name = true
+		case "email":
+			s.Policy.Email = true
+		case "phone":
+			s.Policy.Phone = true
+		case "fax":
+			s.Policy.Fax = true
+		case "notify":
+			s.Policy.NotifyAddress = true
+		case "":
+		default:
+			log.Fatalf("unknown field: %s", field)
+		}
+	}
+
+	snap, err := snapshot.Load(*data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.SetSnapshot(snap)
+
+	// SIGHUPでデータを再読み込み
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			snap, err := snapshot.Load(*data)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			s.SetSnapshot(snap)
+		}
+	}()
+
+	log.Fatal(s.ListenAndServe(*addr))
+}
@@ -0,0 +1,411 @@
+package jpnic
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/pkcs12"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrIncorrectPassword は秘密鍵・PKCS#12のパスワードが誤っている場合に返されます。
+var ErrIncorrectPassword = errors.New("パスワードが誤っています")
+
+// ErrKeyMismatch は秘密鍵と対になる証明書が見つからない場合に返されます。
+var ErrKeyMismatch = errors.New("秘密鍵と一致する証明書が見つかりません")
+
+// loadCertificate はConfigに指定されたクライアント証明書を読み込みます。
+// 優先順位は Certificate > Signer > PfxData > PfxFilePath > CertData/CertFilePath + KeyData/KeyFilePath です。
+func (c *Config) loadCertificate() (tls.Certificate, error) {
+	if c.Certificate != nil {
+		cert := *c.Certificate
+		if len(cert.Certificate) == 0 || cert.PrivateKey == nil {
+			return tls.Certificate{}, fmt.Errorf("証明書または秘密鍵が設定されていません")
+		}
+		if cert.Leaf == nil {
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				return tls.Certificate{}, err
+			}
+			cert.Leaf = leaf
+		}
+		return cert, nil
+	}
+
+	certs, keys, err := c.loadCredentials()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return buildCertificate(certs, keys)
+}
+
+// loadCredentials はConfigに指定された全ての証明書と秘密鍵を読み込みます。
+func (c *Config) loadCredentials() ([]*x509.Certificate, []crypto.PrivateKey, error) {
+	if c.Certificate != nil {
+		var certs []*x509.Certificate
+		for _, der := range c.Certificate.Certificate {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, nil, err
+			}
+			certs = append(certs, cert)
+		}
+		var keys []crypto.PrivateKey
+		if c.Certificate.PrivateKey != nil {
+			keys = append(keys, c.Certificate.PrivateKey)
+		}
+		return certs, keys, nil
+	}
+
+	if c.Signer != nil {
+		return c.loadSignerCredentials()
+	}
+
+	pfxData := c.PfxData
+	if pfxData == nil && c.PfxFilePath != "" {
+		var err error
+		pfxData, err = ioutil.ReadFile(c.PfxFilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if pfxData != nil {
+		password, err := resolveSecret(c.PfxPassSource, c.PfxPass)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer zero(password)
+
+		return decodePfx(pfxData, password)
+	}
+
+	certData, err := c.readCertData()
+	if err != nil {
+		return nil, nil, err
+	}
+	if certData == nil {
+		return nil, nil, fmt.Errorf("クライアント証明書が設定されていません")
+	}
+
+	// 秘密鍵の指定が無い場合は証明書と同じファイルに含まれているものとして扱う
+	keyData := c.KeyData
+	if keyData == nil && c.KeyFilePath != "" {
+		keyData, err = ioutil.ReadFile(c.KeyFilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if keyData == nil {
+		keyData = certData
+	}
+
+	password, err := resolveSecret(c.KeyPassSource, c.KeyPass)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zero(password)
+
+	return decodePEM(certData, keyData, password)
+}
+
+// SignerCertificates はトークン等に保存された証明書を返すSignerが実装するインターフェースです。
+type SignerCertificates interface {
+	Certificates() ([]*x509.Certificate, error)
+}
+
+// loadSignerCredentials はSignerを秘密鍵として、CertData/CertFilePath(無ければSigner)の証明書と組み合わせます。
+func (c *Config) loadSignerCredentials() ([]*x509.Certificate, []crypto.PrivateKey, error) {
+	certData, err := c.readCertData()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var certs []*x509.Certificate
+	if certData != nil {
+		certs, _, err = decodePEM(certData, nil, nil)
+	} else if source, ok := c.Signer.(SignerCertificates); ok {
+		certs, err = source.Certificates()
+	} else {
+		err = fmt.Errorf("Signerに対応するクライアント証明書が設定されていません")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return certs, []crypto.PrivateKey{c.Signer}, nil
+}
+
+// readCertData はCertData(無ければCertFilePath)のPEMを返します。どちらも無い場合はnilを返します。
+func (c *Config) readCertData() ([]byte, error) {
+	if c.CertData != nil || c.CertFilePath == "" {
+		return c.CertData, nil
+	}
+	return ioutil.ReadFile(c.CertFilePath)
+}
+
+// loadCertPool はCAFilePath(またはCAData)の証明書を読み込みます。どちらも無い場合はnil(システムの証明書)を返します。
+func (c *Config) loadCertPool() (*x509.CertPool, error) {
+	caData := c.CAData
+	if caData == nil && c.CAFilePath != "" {
+		var err error
+		caData, err = ioutil.ReadFile(c.CAFilePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if caData == nil {
+		return nil, nil
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("CA証明書を読み込めませんでした")
+	}
+	return pool, nil
+}
+
+func (c *Config) newTLSConfig() (*tls.Config, error) {
+	cert, err := c.loadCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := c.loadCertPool()
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+	}, nil
+}
+
+// newHTTPClient はクライアント証明書を設定したhttp.Clientを返します。メンテナンスの判定と再試行はretryTransportで行います。
+// menuはログに出力するメニュー名です。
+func (c *Config) newHTTPClient(jar http.CookieJar, menu string) (*http.Client, error) {
+	if c.Cassette != nil && c.Cassette.replay {
+		// 再生時は証明書を読み込まず、記録済みのレスポンスは再試行しない
+		return &http.Client{
+			Transport: &retryTransport{
+				base:   &cassetteTransport{cassette: c.Cassette},
+				policy: RetryPolicy{MaxRetries: -1},
+				logger: c.logger(),
+				menu:   menu,
+			},
+			Jar: jar,
+		}, nil
+	}
+
+	tlsConfig, err := c.newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 10 * time.Second,
+		}).DialContext,
+	}
+
+	var roundTripper http.RoundTripper = &retryTransport{base: transport, policy: c.Retry, logger: c.logger(), menu: menu}
+	if c.Cassette != nil {
+		// 展開・再試行後のレスポンスを記録する
+		roundTripper = &cassetteTransport{cassette: c.Cassette, base: roundTripper, scrub: c.redactor(), menu: menu}
+	}
+
+	return &http.Client{
+		Transport: roundTripper,
+		Jar:       jar,
+	}, nil
+}
+
+// decodePfx はPKCS#12ファイルから証明書と秘密鍵を取り出します。
+func decodePfx(data []byte, password []byte) ([]*x509.Certificate, []crypto.PrivateKey, error) {
+	certs, keys, err := decodePKCS12(data, password)
+	if errors.Is(err, ErrUnsupportedAlgorithm) {
+		// RC2/3DES等の旧形式(golang.org/x/crypto/pkcs12はstringでしか受け取れないため、この場合はパスワードを消去できません)
+		return decodeLegacyPKCS12(data, string(password))
+	}
+	return certs, keys, err
+}
+
+func decodeLegacyPKCS12(data []byte, password string) ([]*x509.Certificate, []crypto.PrivateKey, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		if err == pkcs12.ErrIncorrectPassword {
+			return nil, nil, ErrIncorrectPassword
+		}
+		return nil, nil, err
+	}
+
+	var certs []*x509.Certificate
+	var keys []crypto.PrivateKey
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY":
+			key, err := parsePrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	return certs, keys, nil
+}
+
+// decodePEM はPEM形式の証明書(中間証明書を含んでも構いません)と秘密鍵を読み込みます。
+// 秘密鍵はPKCS#1・SEC 1(EC)・PKCS#8と、PBES2で暗号化されたPKCS#8に対応しています。
+func decodePEM(certData, keyData, password []byte) ([]*x509.Certificate, []crypto.PrivateKey, error) {
+	var certs []*x509.Certificate
+	for rest := certData; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	var keys []crypto.PrivateKey
+	for rest := keyData; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		var der []byte
+		switch block.Type {
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if _, ok := block.Headers["DEK-Info"]; ok {
+				return nil, nil, fmt.Errorf("旧形式で暗号化された秘密鍵には対応していません。PKCS#8形式に変換してください")
+			}
+			der = block.Bytes
+		case "ENCRYPTED PRIVATE KEY":
+			if len(password) == 0 {
+				return nil, nil, fmt.Errorf("秘密鍵が暗号化されていますが、パスワードが設定されていません")
+			}
+			var err error
+			der, err = decryptPKCS8(block.Bytes, password)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			continue
+		}
+
+		key, err := parsePrivateKey(der)
+		if err != nil {
+			if block.Type == "ENCRYPTED PRIVATE KEY" {
+				return nil, nil, ErrIncorrectPassword
+			}
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return certs, keys, nil
+}
+
+// parsePrivateKey はPKCS#8・PKCS#1・SEC 1のいずれかの形式の秘密鍵を読み込みます。
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("秘密鍵を読み込めませんでした")
+}
+
+// buildCertificate は秘密鍵と対になる証明書を末端の証明書とし、発行者を辿って中間証明書を並べます。
+// 複数の証明書が含まれている場合でも、末端の証明書は並び順ではなく秘密鍵で判定します。
+func buildCertificate(certs []*x509.Certificate, keys []crypto.PrivateKey) (tls.Certificate, error) {
+	if len(certs) == 0 {
+		return tls.Certificate{}, fmt.Errorf("証明書が見つかりません")
+	}
+	if len(keys) == 0 {
+		return tls.Certificate{}, fmt.Errorf("秘密鍵が見つかりません")
+	}
+
+	for _, key := range keys {
+		for _, leaf := range certs {
+			if !keyMatches(leaf, key) {
+				continue
+			}
+
+			cert := tls.Certificate{
+				Certificate: [][]byte{leaf.Raw},
+				PrivateKey:  key,
+				Leaf:        leaf,
+			}
+			for _, issuer := range chain(leaf, certs) {
+				cert.Certificate = append(cert.Certificate, issuer.Raw)
+			}
+			return cert, nil
+		}
+	}
+
+	return tls.Certificate{}, ErrKeyMismatch
+}
+
+// chain はleafの発行者をcertsの中から順に辿ります。自己署名の証明書に到達するか、発行者が見つからない場合に終了します。
+func chain(leaf *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	var result []*x509.Certificate
+	used := map[*x509.Certificate]bool{leaf: true}
+
+	for current := leaf; !bytes.Equal(current.RawIssuer, current.RawSubject); {
+		var next *x509.Certificate
+		for _, cert := range certs {
+			if !used[cert] && bytes.Equal(cert.RawSubject, current.RawIssuer) {
+				next = cert
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		used[next] = true
+		result = append(result, next)
+		current = next
+	}
+	return result
+}
+
+// keyMatches は証明書の公開鍵が秘密鍵と対になっているかを返します。
+func keyMatches(cert *x509.Certificate, key crypto.PrivateKey) bool {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return false
+	}
+
+	certPublic, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && certPublic.Equal(signer.Public())
+}
@@ -0,0 +1,132 @@
+package jpnic
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// CredentialReport はクライアント証明書の検査結果です。
+type CredentialReport struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	Serial    string    `json:"serial"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	// 有効期限までの残り日数(期限切れの場合は負の値)
+	DaysLeft int `json:"days_left"`
+	// 秘密鍵が証明書と対になっているか
+	KeyMatch bool `json:"key_match"`
+	// CAFilePath(指定が無い場合はシステムの証明書)を起点に検証した証明書チェーンのSubject(末端の証明書から順)
+	Chain      []string `json:"chain"`
+	ChainValid bool     `json:"chain_valid"`
+	// 有効期限が近い等、現時点では利用できるものの対応が必要な項目
+	Warnings []string `json:"warnings"`
+	// 期限切れ・秘密鍵の不一致・チェーンの検証失敗等、利用できない理由
+	Errors []string `json:"errors"`
+}
+
+// OK はErrorsが無い場合にtrueを返します。
+func (r CredentialReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// CheckCredentials はConfigに指定されたクライアント証明書を読み込み、有効期限・秘密鍵・証明書チェーンを検査します。
+// 有効期限までwarnDays日以内の場合はWarningsに追加します。証明書を読み込めなかった場合のみerrorを返します。
+func (c *Config) CheckCredentials(warnDays int) (CredentialReport, error) {
+	return c.checkCredentials(warnDays, time.Now())
+}
+
+func (c *Config) checkCredentials(warnDays int, now time.Time) (CredentialReport, error) {
+	var report CredentialReport
+
+	certs, keys, err := c.loadCredentials()
+	if err != nil {
+		return report, err
+	}
+	if len(certs) == 0 {
+		return report, fmt.Errorf("証明書が見つかりません")
+	}
+
+	leaf := certs[0]
+	var intermediates []*x509.Certificate
+	cert, err := buildCertificate(certs, keys)
+	switch {
+	case err == nil:
+		report.KeyMatch = true
+		leaf = cert.Leaf
+		for _, der := range cert.Certificate[1:] {
+			issuer, err := x509.ParseCertificate(der)
+			if err != nil {
+				return report, err
+			}
+			intermediates = append(intermediates, issuer)
+		}
+	case errors.Is(err, ErrKeyMismatch):
+		report.Errors = append(report.Errors, err.Error())
+		intermediates = certs[1:]
+	default:
+		return report, err
+	}
+
+	report.Subject = leaf.Subject.String()
+	report.Issuer = leaf.Issuer.String()
+	report.Serial = formatSerial(leaf)
+	report.NotBefore = leaf.NotBefore
+	report.NotAfter = leaf.NotAfter
+	report.DaysLeft = int(math.Floor(leaf.NotAfter.Sub(now).Hours() / 24))
+
+	switch {
+	case now.After(leaf.NotAfter):
+		report.Errors = append(report.Errors, fmt.Sprintf("有効期限が切れています (%s)", leaf.NotAfter.Local().Format("2006/01/02 15:04:05")))
+	case now.Before(leaf.NotBefore):
+		report.Errors = append(report.Errors, fmt.Sprintf("有効期間が始まっていません (%s)", leaf.NotBefore.Local().Format("2006/01/02 15:04:05")))
+	case report.DaysLeft <= warnDays:
+		report.Warnings = append(report.Warnings, fmt.Sprintf("有効期限まで残り%d日です (%s)", report.DaysLeft, leaf.NotAfter.Local().Format("2006/01/02 15:04:05")))
+	}
+
+	pool, err := c.loadCertPool()
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil
+	}
+
+	intermediatePool := x509.NewCertPool()
+	for _, issuer := range intermediates {
+		intermediatePool.AddCert(issuer)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediatePool,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("証明書チェーンを検証できませんでした: %s", err))
+		return report, nil
+	}
+
+	report.ChainValid = true
+	for _, issuer := range chains[0] {
+		report.Chain = append(report.Chain, issuer.Subject.String())
+	}
+
+	return report, nil
+}
+
+// formatSerial はシリアル番号をコロン区切りの16進数で返します。
+func formatSerial(cert *x509.Certificate) string {
+	hex := fmt.Sprintf("%X", cert.SerialNumber)
+	if len(hex)%2 == 1 {
+		hex = "0" + hex
+	}
+
+	var parts []string
+	for i := 0; i < len(hex); i += 2 {
+		parts = append(parts, hex[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
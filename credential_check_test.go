@@ -0,0 +1,73 @@
+package jpnic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckCredentials(t *testing.T) {
+	con := Config{
+		CertFilePath: "testdata/client.pem",
+		KeyFilePath:  "testdata/client.key",
+		CAFilePath:   "testdata/ca.pem",
+	}
+
+	report, err := con.CheckCredentials(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || !report.KeyMatch || !report.ChainValid || len(report.Warnings) != 0 {
+		t.Fatalf("report: %+v", report)
+	}
+	if len(report.Chain) != 3 || report.Subject != "CN=EXAMPLE-LIR,O=Example,C=JP" || report.Serial == "" {
+		t.Fatalf("report: %+v", report)
+	}
+
+	// 有効期限の警告
+	report, err = con.checkCredentials(30, report.NotAfter.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || len(report.Warnings) != 1 || report.DaysLeft != 1 {
+		t.Fatalf("report: %+v", report)
+	}
+
+	// 期限切れ
+	report, err = con.checkCredentials(30, report.NotAfter.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || report.DaysLeft >= 0 {
+		t.Fatalf("report: %+v", report)
+	}
+}
+
+func TestCheckCredentialsChain(t *testing.T) {
+	// CA証明書を読み込めない
+	con := Config{
+		CertFilePath: "testdata/client.pem",
+		KeyFilePath:  "testdata/client.key",
+		CAData:       []byte("invalid"),
+	}
+	report, err := con.CheckCredentials(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || report.ChainValid {
+		t.Fatalf("report: %+v", report)
+	}
+
+	// 秘密鍵が一致しない
+	con = Config{
+		CertFilePath: "testdata/ca.pem",
+		KeyFilePath:  "testdata/client.key",
+		CAFilePath:   "testdata/ca.pem",
+	}
+	report, err = con.CheckCredentials(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || report.KeyMatch || report.Subject != "CN=Example Root CA,O=Example CA,C=JP" {
+		t.Fatalf("report: %+v", report)
+	}
+}
@@ -0,0 +1,223 @@
+package jpnic
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// testdata以下の証明書はOpenSSLで作成したテスト用のものです。
+// client.pemは末端の証明書と中間CAの証明書、ca.pemはルートCAの証明書です。
+
+func TestLoadCertificatePEM(t *testing.T) {
+	con := Config{
+		CertFilePath: "testdata/client.pem",
+		KeyFilePath:  "testdata/client.key",
+	}
+	cert, err := con.loadCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf.Subject.CommonName != "EXAMPLE-LIR" || len(cert.Certificate) != 2 {
+		t.Fatalf("leaf: %s, chain: %d", cert.Leaf.Subject, len(cert.Certificate))
+	}
+}
+
+func TestLoadCertificateEncryptedPKCS8(t *testing.T) {
+	certData, err := ioutil.ReadFile("testdata/client.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyData, err := ioutil.ReadFile("testdata/client-enc.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	con := Config{CertData: certData, KeyData: keyData, KeyPass: "password"}
+	cert, err := con.loadCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf.Subject.CommonName != "EXAMPLE-LIR" {
+		t.Fatalf("leaf: %s", cert.Leaf.Subject)
+	}
+
+	con.KeyPass = "wrong"
+	if _, err = con.loadCertificate(); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("expected ErrIncorrectPassword: %v", err)
+	}
+}
+
+func TestLoadCertificatePfx(t *testing.T) {
+	con := Config{PfxFilePath: "testdata/client-legacy.p12", PfxPass: "password"}
+	cert, err := con.loadCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf.Subject.CommonName != "EXAMPLE-LIR" {
+		t.Fatalf("leaf: %s", cert.Leaf.Subject)
+	}
+
+	con.PfxPass = "wrong"
+	if _, err = con.loadCertificate(); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("expected ErrIncorrectPassword: %v", err)
+	}
+}
+
+func TestLoadCertificateTLS(t *testing.T) {
+	pair, err := tls.LoadX509KeyPair("testdata/client.pem", "testdata/client.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	con := Config{Certificate: &pair, PfxFilePath: "testdata/not-found.p12"}
+	cert, err := con.loadCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf == nil || cert.Leaf.Subject.CommonName != "EXAMPLE-LIR" {
+		t.Fatalf("leaf: %+v", cert.Leaf)
+	}
+}
+
+// opaqueSigner は秘密鍵を外部(トークン等)に保持するSignerの代わりです。
+type opaqueSigner struct {
+	crypto.Signer
+	certs []*x509.Certificate
+}
+
+func (s *opaqueSigner) Certificates() ([]*x509.Certificate, error) {
+	return s.certs, nil
+}
+
+func TestLoadCertificateSigner(t *testing.T) {
+	pair, err := tls.LoadX509KeyPair("testdata/client.pem", "testdata/client.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &opaqueSigner{Signer: pair.PrivateKey.(crypto.Signer)}
+
+	con := Config{Signer: signer, CertFilePath: "testdata/client.pem", PfxFilePath: "testdata/not-found.p12"}
+	cert, err := con.loadCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.PrivateKey != crypto.PrivateKey(signer) || len(cert.Certificate) != 2 {
+		t.Fatalf("key: %T, chain: %d", cert.PrivateKey, len(cert.Certificate))
+	}
+
+	// 証明書の指定が無い場合はSignerから取得する
+	con = Config{Signer: signer}
+	if _, err = con.loadCertificate(); err == nil {
+		t.Fatal("expected error")
+	}
+	for _, der := range pair.Certificate {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer.certs = append([]*x509.Certificate{c}, signer.certs...)
+	}
+	cert, err = con.loadCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf.Subject.CommonName != "EXAMPLE-LIR" || len(cert.Certificate) != 2 {
+		t.Fatalf("leaf: %s, chain: %d", cert.Leaf.Subject, len(cert.Certificate))
+	}
+}
+
+func TestLoadCertificateMismatch(t *testing.T) {
+	con := Config{CertFilePath: "testdata/ca.pem", KeyFilePath: "testdata/client.key"}
+	if _, err := con.loadCertificate(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLoadCertificatePfxAES(t *testing.T) {
+	// client-aes.p12: OpenSSL 3の既定値(AES-256-CBC, MAC: SHA-256)
+	// client-chain.p12: ルートCAを含む3枚の証明書(AES-128-CBC, MAC: SHA-512)
+	for _, path := range []string{"testdata/client-aes.p12", "testdata/client-chain.p12"} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		con := Config{PfxData: data, PfxPass: "password"}
+		cert, err := con.loadCertificate()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if cert.Leaf.Subject.CommonName != "EXAMPLE-LIR" {
+			t.Fatalf("%s: leaf: %s", path, cert.Leaf.Subject)
+		}
+
+		con.PfxPass = "wrong"
+		if _, err = con.loadCertificate(); !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("%s: expected ErrIncorrectPassword: %v", path, err)
+		}
+	}
+}
+
+func TestBuildCertificateOrder(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/client-chain.p12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, keys, err := decodePKCS12(data, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 3 {
+		t.Fatalf("certs: %d", len(certs))
+	}
+
+	// 末端の証明書が先頭でなくても、秘密鍵と対になる証明書から発行者の順に並べる
+	reversed := []*x509.Certificate{certs[2], certs[1], certs[0]}
+	cert, err := buildCertificate(reversed, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, der := range cert.Certificate {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, c.Subject.CommonName)
+	}
+	if strings.Join(names, ",") != "EXAMPLE-LIR,Example Intermediate CA,Example Root CA" {
+		t.Fatalf("chain: %v", names)
+	}
+}
+
+func TestIterationLimit(t *testing.T) {
+	// 繰り返し回数が上限を超える場合は鍵導出を行わない
+	mac := macData{Mac: digestInfo{Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}}, Iterations: maxIterations + 1}
+	if err := verifyPKCS12MAC(mac, nil, []byte("password")); err == nil || errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("mac: %v", err)
+	}
+
+	kdf, err := asn1.Marshal(pbkdf2Params{Salt: []byte("salt"), IterationCount: maxIterations + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdf}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = decryptPBES2(pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}, nil, []byte("password"))
+	if err == nil || !strings.Contains(err.Error(), "繰り返し回数") {
+		t.Fatalf("pbes2: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package exporter
+
+import (
+	"context"
+	"github.com/homenoc/jpnic-go"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const namespace = "jpnic_"
+
+// Exporter はJPNICの資源管理者情報・登録情報・申請一覧を定期的に取得し、Prometheus形式で公開します。
+type Exporter struct {
+	// IPv4/IPv6の登録情報検索に使う証明書。資源管理者情報と申請一覧はIPv4側(無ければIPv6側)で取得します。
+	IPv4 *jpnic.Config
+	IPv6 *jpnic.Config
+	// 取得間隔(0の場合は1時間)
+	Interval time.Duration
+	// クライアント証明書の有効期限が近いと判定する日数(0の場合は30日)
+	WarnDays int
+
+	mu      sync.RWMutex
+	current *metrics
+}
+
+// Run はctxがキャンセルされるまでInterval毎に取得を繰り返します。
+func (e *Exporter) Run(ctx context.Context) {
+	interval := e.Interval
+	if interval == 0 {
+		interval = time.Hour
+	}
+
+	e.Scrape()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Scrape()
+		}
+	}
+}
+
+// Scrape はJPNICから情報を取得し、公開するメトリクスを更新します。
+func (e *Exporter) Scrape() {
+	m := newMetrics()
+	start := time.Now()
+
+	if con := e.defaultConfig(); con != nil {
+		info, _, err := con.GetResourceManagement()
+		scrapeResult(m, "resource", err)
+		if err == nil {
+			m.merge(collectResource(info))
+		}
+
+		requests, err := con.GetRequestList("")
+		scrapeResult(m, "requests", err)
+		if err == nil {
+			m.merge(collectRequests(requests))
+		}
+	}
+
+	warnDays := e.WarnDays
+	if warnDays == 0 {
+		warnDays = 30
+	}
+	if e.IPv4 != nil {
+		report, err := e.IPv4.CheckCredentials(warnDays)
+		scrapeResult(m, "certificate_ipv4", err)
+		if err == nil {
+			m.merge(collectCredentials("ipv4", report))
+		}
+	}
+	if e.IPv6 != nil {
+		report, err := e.IPv6.CheckCredentials(warnDays)
+		scrapeResult(m, "certificate_ipv6", err)
+		if err == nil {
+			m.merge(collectCredentials("ipv6", report))
+		}
+	}
+
+	if e.IPv4 != nil {
+		infos, _, err := e.IPv4.SearchIPv4(jpnic.SearchIPv4{Myself: true})
+		scrapeResult(m, "ipv4", err)
+		if err == nil {
+			m.merge(collectIPv4(infos))
+		}
+	}
+
+	if e.IPv6 != nil {
+		infos, _, err := e.IPv6.SearchIPv6(jpnic.SearchIPv6{Myself: true})
+		scrapeResult(m, "ipv6", err)
+		if err == nil {
+			m.merge(collectIPv6(infos))
+		}
+	}
+
+	m.set(namespace+"scrape_duration_seconds", "Duration of the last scrape of JPNIC.", time.Since(start).Seconds())
+	m.set(namespace+"scrape_timestamp_seconds", "Unix time of the last scrape of JPNIC.", float64(time.Now().Unix()))
+
+	e.mu.Lock()
+	e.current = m
+	e.mu.Unlock()
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if e.current == nil {
+		return
+	}
+	e.current.write(w)
+}
+
+func (e *Exporter) defaultConfig() *jpnic.Config {
+	if e.IPv4 != nil {
+		return e.IPv4
+	}
+	return e.IPv6
+}
+
+func scrapeResult(m *metrics, target string, err error) {
+	success := 1.0
+	if err != nil {
+		success = 0
+	}
+	m.set(namespace+"scrape_success", "Whether the last scrape of the target succeeded.", success, "target", target)
+}
+
+func collectResource(info jpnic.ResourceInfo) *metrics {
+	m := newMetrics()
+	manager := info.ResourceManagerInfo.Ryakusyo
+
+	m.set(namespace+"resource_utilization_ratio", "Utilization ratio (%) of the resource manager.", info.UtilizationRatio, "resource_manager", manager)
+	m.set(namespace+"resource_used_addresses", "Number of used addresses of the resource manager.", float64(info.UsedAddress), "resource_manager", manager)
+	m.set(namespace+"resource_addresses", "Number of all addresses of the resource manager.", float64(info.AllAddress), "resource_manager", manager)
+	m.set(namespace+"resource_ad_ratio", "AD ratio of the resource manager.", info.ADRatio, "resource_manager", manager)
+
+	for _, block := range info.ResourceCIDRBlock {
+		m.set(namespace+"cidr_block_utilization_ratio", "Utilization ratio (%) of the CIDR block.", block.UtilizationRatio, "resource_manager", manager, "cidr_block", block.Address)
+		m.set(namespace+"cidr_block_used_addresses", "Number of used addresses of the CIDR block.", float64(block.UsedAddress), "resource_manager", manager, "cidr_block", block.Address)
+		m.set(namespace+"cidr_block_addresses", "Number of all addresses of the CIDR block.", float64(block.AllAddress), "resource_manager", manager, "cidr_block", block.Address)
+	}
+
+	return m
+}
+
+func collectCredentials(target string, report jpnic.CredentialReport) *metrics {
+	m := newMetrics()
+
+	valid, expiring := 0.0, 0.0
+	if report.OK() {
+		valid = 1
+	}
+	if len(report.Warnings) != 0 {
+		expiring = 1
+	}
+
+	m.set(namespace+"certificate_not_after_timestamp_seconds", "Unix time when the client certificate expires.", float64(report.NotAfter.Unix()), "target", target, "subject", report.Subject, "serial", report.Serial)
+	m.set(namespace+"certificate_valid", "Whether the client certificate is usable (not expired, key matches and chain verified).", valid, "target", target)
+	m.set(namespace+"certificate_expiring", "Whether the client certificate expires within the warning period.", expiring, "target", target)
+
+	return m
+}
+
+func collectIPv4(infos []jpnic.InfoIPv4) *metrics {
+	var kinds []string
+	for _, info := range infos {
+		kinds = append(kinds, info.KindID)
+	}
+	return collectNetworks("4", kinds)
+}
+
+func collectIPv6(infos []jpnic.InfoIPv6) *metrics {
+	var kinds []string
+	for _, info := range infos {
+		kinds = append(kinds, info.KindID)
+	}
+	return collectNetworks("6", kinds)
+}
+
+func collectNetworks(version string, kinds []string) *metrics {
+	m := newMetrics()
+
+	count := make(map[string]int)
+	var order []string
+	for _, kind := range kinds {
+		if _, ok := count[kind]; !ok {
+			order = append(order, kind)
+		}
+		count[kind]++
+	}
+
+	for _, kind := range order {
+		m.set(namespace+"networks", "Number of registered networks per kind.", float64(count[kind]), "version", version, "kind", kind)
+	}
+
+	return m
+}
+
+func collectRequests(requests []jpnic.RequestInfo) *metrics {
+	m := newMetrics()
+
+	pending := 0
+	for _, request := range requests {
+		if !request.RequestStatus().IsTerminal() {
+			pending++
+		}
+	}
+	m.set(namespace+"pending_requests", "Number of requests which are not finished yet.", float64(pending))
+
+	return m
+}
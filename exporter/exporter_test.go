@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"bytes"
+	"github.com/homenoc/jpnic-go"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollect(t *testing.T) {
+	m := newMetrics()
+	m.merge(collectResource(jpnic.ResourceInfo{
+		ResourceManagerInfo: jpnic.ResourceManagerInfo{Ryakusyo: "HOMENOC"},
+		UtilizationRatio:    87.5,
+		UsedAddress:         448,
+		AllAddress:          512,
+		ADRatio:             0.91,
+		ResourceCIDRBlock: []jpnic.ResourceCIDRBlock{
+			{Address: "192.0.2.0/24", UtilizationRatio: 75, UsedAddress: 192, AllAddress: 256},
+		},
+	}))
+	m.merge(collectIPv4([]jpnic.InfoIPv4{{KindID: "割当"}, {KindID: "割当"}, {KindID: "インフラ"}}))
+	m.merge(collectRequests([]jpnic.RequestInfo{{Status: "完了"}, {Status: "審議中"}, {Status: "却下"}}))
+	m.merge(collectCredentials("ipv4", jpnic.CredentialReport{
+		Subject:  "CN=EXAMPLE-LIR",
+		Serial:   "01",
+		NotAfter: time.Unix(1700000000, 0),
+		Warnings: []string{"有効期限まで残り10日です"},
+	}))
+
+	var buf bytes.Buffer
+	if err := m.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE jpnic_resource_utilization_ratio gauge\n",
+		`jpnic_resource_utilization_ratio{resource_manager="HOMENOC"} 87.5` + "\n",
+		`jpnic_resource_used_addresses{resource_manager="HOMENOC"} 448` + "\n",
+		`jpnic_cidr_block_addresses{resource_manager="HOMENOC",cidr_block="192.0.2.0/24"} 256` + "\n",
+		`jpnic_networks{version="4",kind="割当"} 2` + "\n",
+		`jpnic_networks{version="4",kind="インフラ"} 1` + "\n",
+		"jpnic_pending_requests 1\n",
+		`jpnic_certificate_not_after_timestamp_seconds{target="ipv4",subject="CN=EXAMPLE-LIR",serial="01"} 1.7e+09` + "\n",
+		`jpnic_certificate_valid{target="ipv4"} 1` + "\n",
+		`jpnic_certificate_expiring{target="ipv4"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	m := newMetrics()
+	m.set("test", "help", 1, "name", "a\"b\\c\nd")
+
+	var buf bytes.Buffer
+	m.write(&buf)
+
+	if !strings.Contains(buf.String(), `test{name="a\"b\\c\nd"} 1`) {
+		t.Fatal(buf.String())
+	}
+}
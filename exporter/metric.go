@@ -0,0 +1,115 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	labels []label
+	value  float64
+}
+
+type metric struct {
+	name    string
+	help    string
+	samples []sample
+}
+
+// metrics はPrometheusのtext形式で出力するGaugeの集合です。
+type metrics struct {
+	list  []*metric
+	index map[string]*metric
+}
+
+func newMetrics() *metrics {
+	return &metrics{index: make(map[string]*metric)}
+}
+
+func (m *metrics) set(name, help string, value float64, labels ...string) {
+	mt, ok := m.index[name]
+	if !ok {
+		mt = &metric{name: name, help: help}
+		m.index[name] = mt
+		m.list = append(m.list, mt)
+	}
+
+	var s sample
+	for i := 0; i+1 < len(labels); i += 2 {
+		s.labels = append(s.labels, label{name: labels[i], value: labels[i+1]})
+	}
+	s.value = value
+	mt.samples = append(mt.samples, s)
+}
+
+// merge は他のmetricsの内容を追加します。
+func (m *metrics) merge(other *metrics) {
+	for _, mt := range other.list {
+		for _, s := range mt.samples {
+			var labels []string
+			for _, l := range s.labels {
+				labels = append(labels, l.name, l.value)
+			}
+			m.set(mt.name, mt.help, s.value, labels...)
+		}
+	}
+}
+
+func (m *metrics) write(w io.Writer) error {
+	list := make([]*metric, len(m.list))
+	copy(list, m.list)
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+
+	for _, mt := range list {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", mt.name, escapeHelp(mt.help), mt.name); err != nil {
+			return err
+		}
+		for _, s := range mt.samples {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", mt.name, formatLabels(s.labels), formatValue(s.value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatLabels(labels []label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var str []string
+	for _, l := range labels {
+		str = append(str, l.name+`="`+escapeLabel(l.value)+`"`)
+	}
+	return "{" + strings.Join(str, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(str string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(str)
+}
+
+func escapeLabel(str string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(str)
+}
@@ -4,6 +4,8 @@ go 1.17
 
 require (
 	github.com/PuerkitoBio/goquery v1.8.0
+	github.com/andybalholm/brotli v1.1.0
+	github.com/miekg/pkcs11 v1.1.1
 	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	golang.org/x/text v0.3.7
 )
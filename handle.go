@@ -0,0 +1,142 @@
+package jpnic
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// handlePattern はJPNICハンドル(例: YY38053JP)に一致します。
+var handlePattern = regexp.MustCompile(`[A-Z]+[0-9]+JP`)
+
+var validHandleRegexp = regexp.MustCompile(`^(?:` + handlePattern.String() + `)$`)
+
+// ValidHandle はstr全体がJPNICハンドル(グループハンドル)の形式の場合にtrueを返します。
+func ValidHandle(str string) bool {
+	return validHandleRegexp.MatchString(str)
+}
+
+// RegisterHandle は担当者(IsJPNICHandleがtrue)またはグループ(false)のJPNICハンドルを新規に登録し、受付番号を返します。
+// JPNICハンドルは申請が処理された後に発行されるため、WaitForHandleに受付番号を渡して取得します。
+func (c *Config) RegisterHandle(input JPNICHandleInput) (string, error) {
+	return c.RegisterHandleContext(context.Background(), input)
+}
+
+// RegisterHandleContext はctxのキャンセル・スパンを引き継いでRegisterHandleを実行します。
+func (c *Config) RegisterHandleContext(ctx context.Context, input JPNICHandleInput) (string, error) {
+	ctx, span := c.startOperation(ctx, "RegisterHandle", Attr("jpnic.kind", handleKind(input)))
+
+	err := validateHandleInput(input)
+	if err != nil {
+		endSpan(span, err)
+		return "", err
+	}
+
+	recepNo, err := c.submitHandle(ctx, input, true)
+	endSpan(span, err)
+	return recepNo, err
+}
+
+// WaitForHandle はRegisterHandleの受付番号の申請が完了するまで待ち、申請情報(詳細)から発行されたJPNICハンドルを返します。
+// 申請が却下・取下げとなった場合はエラーを返します。
+func (c *Config) WaitForHandle(ctx context.Context, recepNo string) (string, error) {
+	info, err := c.WaitForRequest(ctx, recepNo)
+	if err != nil {
+		return "", err
+	}
+	if status := info.RequestStatus(); status != RequestStatusCompleted {
+		return "", fmt.Errorf("受付番号 %s の申請は%sです", strings.TrimSpace(recepNo), status)
+	}
+
+	detail, err := c.GetRequestDetailContext(ctx, recepNo)
+	if err != nil {
+		return "", err
+	}
+
+	handle := issuedHandle(detail)
+	if handle == "" {
+		return "", fmt.Errorf("受付番号 %s の申請情報(詳細)にJPNICハンドルがありません", strings.TrimSpace(recepNo))
+	}
+	return handle, nil
+}
+
+// issuedHandle は申請情報(詳細)の項目から発行されたJPNICハンドルを探します。
+func issuedHandle(detail RequestDetail) string {
+	for _, field := range detail.Fields {
+		if !strings.Contains(field.Name, "ハンドル") {
+			continue
+		}
+		if handle := handlePattern.FindString(field.Value); handle != "" {
+			return handle
+		}
+	}
+	return ""
+}
+
+func handleKind(input JPNICHandleInput) string {
+	if input.IsJPNICHandle {
+		return "person"
+	}
+	return "group"
+}
+
+// validateHandleInput は新規登録の必須項目を確認します。
+// 担当者は氏名、グループはグループ名が必要で、グループには部署・肩書を指定できません。
+func validateHandleInput(input JPNICHandleInput) error {
+	if input.JPNICHandle != "" {
+		return fmt.Errorf("新規登録ではJPNICハンドルを指定できません: %s", input.JPNICHandle)
+	}
+
+	name, nameEn := "氏名", "氏名(英語)"
+	if !input.IsJPNICHandle {
+		name, nameEn = "グループ名", "グループ名(英語)"
+	}
+
+	var missing []string
+	for _, field := range []struct {
+		label string
+		value string
+	}{
+		{name, input.Name},
+		{nameEn, input.NameEn},
+		{"電子メール", input.Email},
+		{"組織名", input.Org},
+		{"組織名(英語)", input.OrgEn},
+		{"郵便番号", input.ZipCode},
+		{"住所", input.Address},
+		{"住所(英語)", input.AddressEn},
+		{"電話番号", input.Tel},
+		{"申請者メールアドレス", input.ApplyMail},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, field.label)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("必須項目が指定されていません: %s", strings.Join(missing, ", "))
+	}
+
+	if !input.IsJPNICHandle && (input.Division != "" || input.DivisionEn != "" || input.Title != "" || input.TitleEn != "") {
+		return fmt.Errorf("グループには部署・肩書を指定できません")
+	}
+
+	for _, field := range []struct {
+		label string
+		value string
+	}{
+		{"電子メール", input.Email},
+		{"通知アドレス", input.NotifyMail},
+		{"申請者メールアドレス", input.ApplyMail},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(field.value); err != nil {
+			return fmt.Errorf("%sが不正です: %s", field.label, field.value)
+		}
+	}
+
+	return nil
+}
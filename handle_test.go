@@ -0,0 +1,140 @@
+package jpnic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testHandleInput() JPNICHandleInput {
+	return JPNICHandleInput{
+		IsJPNICHandle: true,
+		Name:          "日本 太郎",
+		NameEn:        "Taro Nihon",
+		Email:         "taro@example.ad.jp",
+		Org:           "ホームNOC",
+		OrgEn:         "HomeNOC",
+		ZipCode:       "100-0001",
+		Address:       "東京都千代田区千代田1-1",
+		AddressEn:     "1-1 Chiyoda, Chiyoda-ku, Tokyo",
+		Tel:           "03-1234-5678",
+		ApplyMail:     "apply@example.ad.jp",
+	}
+}
+
+func TestValidateHandleInput(t *testing.T) {
+	if err := validateHandleInput(testHandleInput()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(input *JPNICHandleInput)
+		want   string
+	}{
+		{"JPNICハンドル指定", func(input *JPNICHandleInput) { input.JPNICHandle = "YY38053JP" }, "JPNICハンドルを指定できません"},
+		{"担当者の必須項目", func(input *JPNICHandleInput) { input.Name, input.Tel = "", " " }, "必須項目が指定されていません: 氏名, 電話番号"},
+		{"グループの必須項目", func(input *JPNICHandleInput) { input.IsJPNICHandle, input.NameEn = false, "" }, "必須項目が指定されていません: グループ名(英語)"},
+		{"グループの肩書", func(input *JPNICHandleInput) { input.IsJPNICHandle, input.Title = false, "部長" }, "部署・肩書を指定できません"},
+		{"メールアドレス", func(input *JPNICHandleInput) { input.NotifyMail = "notify" }, "通知アドレスが不正です"},
+	} {
+		input := testHandleInput()
+		tt.modify(&input)
+		err := validateHandleInput(input)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestRegisterHandle(t *testing.T) {
+	pages := map[string]string{
+		"/jpnic/certmemberlogin.do": `<meta http-equiv="refresh" content="0;URL=menu.do">`,
+		"/jpnic/menu.do":            `<table><tr><td><table><tr><td><a href="entry.do">担当グループ（担当者）情報登録・変更</a></td></tr></table></td></tr></table>`,
+		"/jpnic/entry.do": `<form action="/jpnic/regist.do">` +
+			`<input name="org.apache.struts.taglib.html.TOKEN" value="T1"><input name="destdisp" value="D1"><input name="aplyid" value="A1"></form>`,
+		"/jpnic/regist.do": `<form action="/jpnic/apply.do">` +
+			`<input name="org.apache.struts.taglib.html.TOKEN" value="T2"><input name="prevDispId" value="P1"></form>` +
+			`上記の申請内容でよろしければ、「確認」ボタンを押してください。`,
+		"/jpnic/apply.do": `<table><tr><td><table>` +
+			`<tr><td>受付番号</td><td> 20261018000123 </td></tr>` +
+			`<tr><td>JPNICハンドル</td><td>TN1234JP</td></tr>` +
+			`</table></td></tr></table>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=Shift_JIS")
+		w.Write(toShiftJISTest(t, page))
+	}))
+	defer srv.Close()
+
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = srv.URL
+
+	con := Config{
+		CertFilePath: "testdata/client.pem",
+		KeyFilePath:  "testdata/client.key",
+		Cassette:     NewCassette(filepath.Join(t.TempDir(), "cassette.json")),
+	}
+	input := testHandleInput()
+	input.Org = "A&B=100%"
+	recepNo, err := con.RegisterHandle(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recepNo != "20261018000123" {
+		t.Fatalf("recepNo: %q", recepNo)
+	}
+
+	// 新規登録ではjpnic_hdlを送信しない
+	if len(con.Cassette.Interactions) != 5 {
+		t.Fatalf("interactions: %d", len(con.Cassette.Interactions))
+	}
+	body := string(con.Cassette.Interactions[3].Request.Body)
+	if strings.Contains(body, "jpnic_hdl") || !strings.Contains(body, "&kind=person&") {
+		t.Fatalf("body: %s", body)
+	}
+	// 値はエスケープして送信する
+	if !strings.Contains(body, "&org_nm_jp=A%26B%3D100%25&") || !strings.HasSuffix(body, "&action=%90%5C%90%BF") {
+		t.Fatalf("body: %s", body)
+	}
+	if body := string(con.Cassette.Interactions[4].Request.Body); !strings.HasSuffix(body, "&inputconf=%8Am%94F") {
+		t.Fatalf("body: %s", body)
+	}
+
+	// 必須項目が無い場合は通信しない
+	input = testHandleInput()
+	input.Email = ""
+	if _, err = con.RegisterHandle(input); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if len(con.Cassette.Interactions) != 5 {
+		t.Fatalf("interactions: %d", len(con.Cassette.Interactions))
+	}
+}
+
+func TestIssuedHandle(t *testing.T) {
+	detail := RequestDetail{Fields: []RequestDetailField{
+		{Name: "申請者", Value: "日本 太郎 (YY38053JP)"},
+		{Name: "グループハンドル", Value: ""},
+		{Name: "JPNICハンドル", Value: " TN1234JP "},
+	}}
+	if handle := issuedHandle(detail); handle != "TN1234JP" {
+		t.Fatalf("handle: %q", handle)
+	}
+	if handle := issuedHandle(RequestDetail{}); handle != "" {
+		t.Fatalf("handle: %q", handle)
+	}
+
+	for str, want := range map[string]bool{"YY38053JP": true, "TN1234JP": true, "X&foo=bar": false, "YY38053JP&a=1": false, "": false} {
+		if ValidHandle(str) != want {
+			t.Errorf("ValidHandle(%q) != %v", str, want)
+		}
+	}
+}
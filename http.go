@@ -1,22 +1,20 @@
 package jpnic
 
 import (
-	"bytes"
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
-	"golang.org/x/crypto/pkcs12"
-	"io/ioutil"
-	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
-	"time"
 )
 
 type request struct {
+	// トレーシングのスパンの親(nilの場合は記録しません)
+	Context context.Context
+	// スパン名(login, menu, form.fetch, form.submit等。空の場合はスパンを作成しません)
+	Step            string
 	Client          *http.Client
 	URL             string
 	Body            string
@@ -26,7 +24,7 @@ type request struct {
 	ServerSessionID string
 }
 
-func (c *Config) initAccess(menuName string) (*http.Client, string, error) {
+func (c *Config) initAccess(ctx context.Context, menuName string) (*http.Client, string, error) {
 	sessionID, err := randomStr()
 	if err != nil {
 		return nil, "", err
@@ -48,50 +46,15 @@ func (c *Config) initAccess(menuName string) (*http.Client, string, error) {
 
 	jar.SetCookies(urlObj, cookies)
 
-	// Load .p12 File
-	p12Bytes, err := ioutil.ReadFile(c.PfxFilePath)
+	client, err := c.newHTTPClient(jar, menuName)
 	if err != nil {
 		return nil, "", err
 	}
 
-	// .p12 decode
-	key, cert, err := pkcs12.Decode(p12Bytes, c.PfxPass)
-	if err != nil {
-		return nil, "", err
-	}
-
-	// Load CA
-	caCertBytes, err := ioutil.ReadFile(c.CAFilePath)
-	if err != nil {
-		return nil, "", err
-	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCertBytes)
-
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{{
-			Certificate: [][]byte{cert.Raw},
-			PrivateKey:  key,
-			Leaf:        cert,
-		}},
-		RootCAs: caCertPool,
-	}
-	tlsConfig.BuildNameToCertificate()
-
-	transport := &http.Transport{
-		TLSClientConfig: tlsConfig,
-		DialContext: (&net.Dialer{
-			Timeout:   10 * time.Second,
-			KeepAlive: 10 * time.Second,
-		}).DialContext,
-	}
-	client := &http.Client{
-		Transport: transport,
-		Jar:       jar,
-	}
-
 	// Login
 	r := request{
+		Context:     ctx,
+		Step:        "login",
 		Client:      client,
 		URL:         baseURL + "/jpnic/certmemberlogin.do",
 		Body:        "",
@@ -105,7 +68,7 @@ func (c *Config) initAccess(menuName string) (*http.Client, string, error) {
 	}
 	defer resp.Body.Close()
 
-	result, _, err := readShiftJIS(resp.Body)
+	result, _, err := readHTML(resp)
 	if err != nil {
 		return nil, "", err
 	}
@@ -116,12 +79,12 @@ func (c *Config) initAccess(menuName string) (*http.Client, string, error) {
 	}
 	resultContent, isExists := doc.Find("meta").Attr("content")
 	if !isExists {
-		return nil, "", fmt.Errorf("エラーが発生しました")
+		return nil, "", newParseError(ctx, r.Step, r.URL, result, fmt.Errorf("エラーが発生しました"))
 	}
 	refreshURL := strings.Split(resultContent, "=")[1]
 
 	// menu
-	menuURL, err := getLink(client, refreshURL, menuName)
+	menuURL, err := getLink(ctx, client, refreshURL, menuName)
 	if err != nil {
 		return nil, "", err
 	}
@@ -137,35 +100,31 @@ func getJSessionID(cookies []*http.Cookie) string {
 			jsessionID = tmp.Value
 			break
 		}
-		//log.Println(tmp.Name)
-		//log.Println(tmp.Value)
 	}
 	return jsessionID
 }
 
 func (r *request) post() (*http.Response, error) {
-	req, err := http.NewRequest("POST", r.URL, ioutil.NopCloser(bytes.NewBufferString(r.Body)))
-
-	req.Header.Add("User-Agent", r.UserAgent)
-	req.Header.Add("Content-Type", r.ContentType)
-	req.Header.Add("Host", "iphostmaster.nic.ad.jp")
-	req.Header.Add("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-	req.Header.Add("Accept-Language", "en-US,en;q=0.5")
-	req.Header.Add("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Add("Connection", "keep-alive")
-	//req.Header.Add("Upgrade-Insecure-Requests","1")
-	req.Header.Add("Sec-Fetch-Dest", "document")
-	req.Header.Add("Sec-Fetch-Mode", "navigate")
-	req.Header.Add("Sec-Fetch-Site", "same-origin")
-
-	resp, err := r.Client.Do(req)
+	req, err := http.NewRequestWithContext(r.context(), "POST", r.URL, strings.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	r.setHeader(req)
 
-	return resp, err
+	return r.do(req)
 }
 
 func (r *request) get() (*http.Response, error) {
-	req, err := http.NewRequest("GET", r.URL, nil)
+	req, err := http.NewRequestWithContext(r.context(), "GET", r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.setHeader(req)
+
+	return r.do(req)
+}
 
+func (r *request) setHeader(req *http.Request) {
 	req.Header.Add("User-Agent", r.UserAgent)
 	req.Header.Add("Content-Type", r.ContentType)
 	req.Header.Add("Host", "iphostmaster.nic.ad.jp")
@@ -177,22 +136,41 @@ func (r *request) get() (*http.Response, error) {
 	req.Header.Add("Sec-Fetch-Dest", "document")
 	req.Header.Add("Sec-Fetch-Mode", "navigate")
 	req.Header.Add("Sec-Fetch-Site", "same-origin")
+}
 
-	resp, err := r.Client.Do(req)
+// do はリクエストを送信し、エラー応答(4xx/5xx)の場合はエラーを返します。
+// メンテナンス中の場合はMaintenanceError(ErrMaintenance)を返します。
+func (r *request) do(req *http.Request) (resp *http.Response, err error) {
+	span := Span(nopSpan{})
+	if r.Step != "" {
+		var ctx context.Context
+		ctx, span = startSpan(req.Context(), r.Step, Attr("http.method", req.Method), Attr("http.target", tracePath(req.URL)))
+		req = req.WithContext(ctx)
+		defer func() { endSpan(span, err) }()
+	}
+
+	resp, err = r.Client.Do(req)
 	if err != nil {
-		return resp, err
+		return nil, err
 	}
+	span.SetAttributes(Attr("http.status_code", resp.StatusCode))
 
-	switch resp.StatusCode {
-	case 503:
-		body, _, _ := readEUCJP(resp.Body)
-		// メンテナンス判定
-		if strings.Contains(body, "ただいまメンテナンス中です") {
-			return resp, fmt.Errorf("[%d] 現在、メンテナンス中のためデータ取得が出来ません。", resp.StatusCode)
-		} else {
-			return resp, fmt.Errorf("Status Code: %d ", resp.StatusCode)
-		}
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Status Code: %d ", resp.StatusCode)
 	}
 
-	return resp, err
+	return resp, nil
+}
+
+func (r *request) context() context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+	return r.Context
+}
+
+// tracePath はセッションID(;jsessionid=...)を除いたパスを返します。
+func tracePath(u *url.URL) string {
+	return strings.SplitN(u.Path, ";", 2)[0]
 }
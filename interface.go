@@ -1,5 +1,7 @@
 package jpnic
 
+import "encoding/json"
+
 type Result struct {
 	Err           error
 	ResultErr     []error
@@ -95,8 +97,18 @@ type TechUser struct {
 }
 
 type Etc struct {
-	CertID   string `json:"cert_id"`
+	CertID string `json:"cert_id"`
+	// JSONからの読み込みには対応していますが、書き出す際には含めません。
 	Password string `json:"password"`
+	// Passwordの代わりにパスワードを取得する場合に指定(取得したパスワードは送信後に消去します)
+	PasswordSource SecretSource `json:"-"`
+}
+
+// MarshalJSON はパスワードを除いて書き出します。
+func (e Etc) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		CertID string `json:"cert_id"`
+	}{CertID: e.CertID})
 }
 
 type InfoDetail struct {
@@ -228,6 +240,47 @@ type RequestInfo struct {
 	ApplyDate    string `json:"apply_date"`
 	CompleteDate string `json:"complete_date"`
 	Status       string `json:"status"`
+	DetailLink   string `json:"detail_link"`
+}
+
+type RequestDetail struct {
+	RecepNo      string               `json:"recep_no"`
+	DeliNo       string               `json:"deli_no"`
+	ApplyKind    string               `json:"apply_kind"`
+	ApplyClass   string               `json:"apply_class"`
+	Applicant    string               `json:"applicant"`
+	ApplyDate    string               `json:"apply_date"`
+	CompleteDate string               `json:"complete_date"`
+	Status       string               `json:"status"`
+	Fields       []RequestDetailField `json:"fields"`  // 申請内容
+	History      []RequestHistory     `json:"history"` // 処理履歴
+	Remarks      []string             `json:"remarks"` // JPNICからの連絡事項・備考
+}
+
+type RequestDetailField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type RequestHistory struct {
+	Date   string `json:"date"`
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+type RequestSearch struct {
+	StartRecepNo  string `json:"start_recep_no"` // 受付番号(開始)
+	EndRecepNo    string `json:"end_recep_no"`   // 受付番号(終了)
+	DeliNo        string `json:"deli_no"`        // 審議番号
+	ApplyKind     string `json:"apply_kind"`     // 申請種別
+	ApplyClass    string `json:"apply_class"`    // 申請区分
+	Ryakusho      string `json:"ryakusho"`       // 資源管理者略称
+	ApplyStart    string `json:"apply_start"`    // 申請日(開始)
+	ApplyEnd      string `json:"apply_end"`      // 申請日(終了)
+	CompleteStart string `json:"complete_start"` // 完了日(開始)
+	CompleteEnd   string `json:"complete_end"`   // 完了日(終了)
+	StatusID      string `json:"status_id"`      // ステータス
+	MaxPages      int    `json:"max_pages"`      // 取得する最大ページ数(0の場合は全ページ)
 }
 
 type ReturnIPv6List struct {
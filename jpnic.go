@@ -3,14 +3,13 @@ package jpnic
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
-	"golang.org/x/crypto/pkcs12"
-	"io/ioutil"
-	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -22,73 +21,120 @@ var contentType = "application/x-www-form-urlencoded"
 var baseURL = "https://iphostmaster.nic.ad.jp"
 
 type Config struct {
-	URL         string
+	URL string
+	// PKCS#12形式のクライアント証明書
 	PfxFilePath string
 	PfxPass     string
+	// PfxPassの代わりにパスワードを取得する場合に指定(取得したパスワードは利用後に消去します)
+	PfxPassSource SecretSource
+	// PfxFilePathの代わりにメモリ上のPKCS#12を使う場合に指定
+	PfxData []byte
+	// PEM形式のクライアント証明書・秘密鍵(PfxFilePath/PfxDataが無い場合に使用)
+	// KeyFilePathが無い場合は、秘密鍵が証明書と同じファイルに含まれているものとして扱います。
+	CertFilePath string
+	KeyFilePath  string
+	// 暗号化されたPKCS#8秘密鍵のパスワード
+	KeyPass string
+	// KeyPassの代わりにパスワードを取得する場合に指定
+	KeyPassSource SecretSource
+	// CertFilePath/KeyFilePathの代わりにメモリ上のPEMを使う場合に指定
+	CertData []byte
+	KeyData  []byte
+	// 秘密鍵をPKCS#11トークン等に保持する場合に指定(jpnic-go/pkcs11を参照)
+	// 証明書はCertFilePath/CertDataで指定し、指定が無い場合はSignerのCertificates()(実装されている場合)から取得します。
+	Signer crypto.Signer
+	// 構築済みの証明書(指定した場合は他の証明書の設定より優先されます)
+	Certificate *tls.Certificate
 	CAFilePath  string
+	// CAFilePathの代わりにメモリ上のPEMを使う場合に指定
+	CAData []byte
+	// GETの再試行の設定(ゼロ値の場合は3回まで再試行)
+	Retry RetryPolicy
+	// ログの出力先(nilの場合は出力しません)
+	Logger Logger
+	// トレーシング(nilの場合は記録しません)
+	Tracer Tracer
+	// HTTPの記録(NewCassette)・再生(LoadCassette)
+	Cassette *Cassette
+	// 解析に失敗したページの保存先(空の場合は保存しません)
+	CaptureDir string
 }
 
 func (c *Config) Send(input WebTransaction) Result {
+	return c.SendContext(context.Background(), input)
+}
+
+// SendContext はctxのキャンセル・スパンを引き継いでSendを実行します。
+func (c *Config) SendContext(ctx context.Context, input WebTransaction) Result {
+	ctx, span := c.startOperation(ctx, "Send")
+	result := c.send(ctx, input)
+	span.SetAttributes(Attr("jpnic.recep_no", result.RecepNo))
+	endSpan(span, result.Err)
+	return result
+}
+
+func (c *Config) send(ctx context.Context, input WebTransaction) Result {
 	var result Result
 
-	// Load .p12 File
-	p12Bytes, err := ioutil.ReadFile(c.PfxFilePath)
+	client, err := c.newHTTPClient(nil, "WebTransaction")
 	if err != nil {
 		result.Err = err
 		return result
 	}
 
-	// .p12 decode
-	key, cert, err := pkcs12.Decode(p12Bytes, c.PfxPass)
+	//req.Header.Set("User-Agent", "Golang_Spider_Bot/3.0")
+
+	logger := c.logger(input.Etc.Password)
+
+	passwordSource := input.Etc.PasswordSource
+	if passwordSource != nil {
+		input.Etc.Password = ""
+	}
+
+	str, err := Marshal(input)
 	if err != nil {
 		result.Err = err
 		return result
 	}
 
-	// Load CA
-	caCertBytes, err := ioutil.ReadFile(c.CAFilePath)
+	// utf-8 => shift-jis
+	_, strByte, err := toShiftJIS(str)
 	if err != nil {
 		result.Err = err
 		return result
 	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCertBytes)
 
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{{
-			Certificate: [][]byte{cert.Raw},
-			PrivateKey:  key,
-			Leaf:        cert,
-		}},
-		RootCAs: caCertPool,
+	// PasswordSourceのパスワードは文字列にせず、末尾のPSWD=に直接追加して送信後に消去する
+	if passwordSource != nil {
+		password, err := passwordSource.Secret()
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		strByte = append(bytes.TrimSuffix(strByte, []byte("\n")), password...)
+		strByte = append(strByte, '\n')
+		zero(password)
+		defer zero(strByte)
 	}
-	tlsConfig.BuildNameToCertificate()
-	transport := &http.Transport{TLSClientConfig: tlsConfig}
-	client := &http.Client{Transport: transport}
-
-	//req.Header.Set("User-Agent", "Golang_Spider_Bot/3.0")
-
-	contentType = "text/html"
 
-	str, err := Marshal(input)
+	req, err := http.NewRequestWithContext(ctx, "POST", c.URL, bytes.NewBuffer(strByte))
 	if err != nil {
 		result.Err = err
 		return result
 	}
+	req.Header.Set("Content-Type", "text/html")
 
-	// utf-8 => shift-jis
-	_, strByte, err := toShiftJIS(str)
+	resp, err := client.Do(req)
 	if err != nil {
 		result.Err = err
 		return result
 	}
+	defer resp.Body.Close()
 
-	resp, err := client.Post(c.URL, contentType, bytes.NewBuffer(strByte))
-	if err != nil {
-		result.Err = err
+	if resp.StatusCode != http.StatusOK {
+		result.Err = fmt.Errorf("Status Code: %d ", resp.StatusCode)
 		return result
 	}
-	defer resp.Body.Close()
 
 	scanner := bufio.NewScanner(resp.Body)
 
@@ -157,16 +203,33 @@ func (c *Config) Send(input WebTransaction) Result {
 
 	result.ResultErr = errStr
 
+	logger.Log(LogDebug, "WebTransaction", "ret", ret, "ret_code", strings.Join(retCode, ","), "recep_no", result.RecepNo)
+
 	return result
 }
 
 func (c *Config) SearchIPv4(search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail, error) {
-	client, menuURL, err := c.initAccess("登録情報検索(IPv4)")
+	return c.SearchIPv4Context(context.Background(), search)
+}
+
+// SearchIPv4Context はctxのキャンセル・スパンを引き継いでSearchIPv4を実行します。
+func (c *Config) SearchIPv4Context(ctx context.Context, search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail, error) {
+	ctx, span := c.startOperation(ctx, "SearchIPv4", Attr("jpnic.detail", search.IsDetail))
+	infos, handles, err := c.searchIPv4(ctx, search)
+	span.SetAttributes(Attr("jpnic.rows", len(infos)), Attr("jpnic.handles", len(handles)))
+	endSpan(span, err)
+	return infos, handles, err
+}
+
+func (c *Config) searchIPv4(ctx context.Context, search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail, error) {
+	client, menuURL, err := c.initAccess(ctx, "登録情報検索(IPv4)")
 	if err != nil {
 		return nil, nil, err
 	}
 
 	r := request{
+		Context:     ctx,
+		Step:        "form.fetch",
 		Client:      client,
 		URL:         baseURL + "/jpnic/" + menuURL,
 		Body:        "",
@@ -179,7 +242,7 @@ func (c *Config) SearchIPv4(search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail,
 		return nil, nil, err
 	}
 
-	resBody, _, err := readShiftJIS(resp.Body)
+	resBody, _, err := readHTML(resp)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -191,11 +254,11 @@ func (c *Config) SearchIPv4(search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail,
 
 	submitURL, isExists := doc.Find("form").Attr("action")
 	if !isExists {
-		return nil, nil, fmt.Errorf("submit URLが取得できませんでした")
+		return nil, nil, newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("submit URLが取得できませんでした"))
 	}
 	submitID, isExists := doc.Find("form").Find("input").Attr("value")
 	if !isExists {
-		return nil, nil, fmt.Errorf("inputフォームのIDが取得できませんでした")
+		return nil, nil, newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("inputフォームのIDが取得できませんでした"))
 	}
 
 	var requestStr string
@@ -211,7 +274,7 @@ func (c *Config) SearchIPv4(search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail,
 			}
 		})
 		if !isExists {
-			return nil, nil, fmt.Errorf("資源管理者略称が見つかりませんでした")
+			return nil, nil, newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("資源管理者略称が見つかりませんでした"))
 		}
 		requestStr = "destdisp=" + submitID
 		requestStr += "&ipaddr=" + search.IPAddress
@@ -266,6 +329,8 @@ func (c *Config) SearchIPv4(search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail,
 	}
 
 	r = request{
+		Context:     ctx,
+		Step:        "form.submit",
 		Client:      client,
 		URL:         baseURL + submitURL,
 		Body:        reqBody,
@@ -278,7 +343,7 @@ func (c *Config) SearchIPv4(search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail,
 		return nil, nil, err
 	}
 
-	resBody, _, err = readShiftJIS(resp.Body)
+	resBody, _, err = readHTML(resp)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -300,10 +365,13 @@ func (c *Config) SearchIPv4(search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail,
 		isJPNICHandleExist[handle] = 0
 	}
 
-	doc.Find("table").Children().Find("td").Each(func(_ int, tableHtml *goquery.Selection) {
+	// 一覧の解析(詳細情報の取得を含む)
+	parseCtx, parseSpan := startSpan(ctx, "parse")
+	// 詳細情報・JPNICハンドルの取得に失敗した場合は、その時点で解析を中断してエラーを返す
+	doc.Find("table").Children().Find("td").EachWithBreak(func(_ int, tableHtml *goquery.Selection) bool {
 		className, _ := tableHtml.Attr("class")
 		if className != "dataRow_mnt04" {
-			return
+			return true
 		}
 		dataStr := strings.TrimSpace(tableHtml.Text())
 		switch index {
@@ -332,42 +400,43 @@ func (c *Config) SearchIPv4(search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail,
 			info.KindID = dataStr
 			// 詳細情報の取得
 			if search.IsDetail && allCounter != 0 {
-				//log.Println("==========")
-				time.Sleep(1 * time.Second)
-				//log.Println("req1")
-				info.InfoDetail, err = getInfoDetail(client, info.DetailLink)
+				if err = sleepContext(parseCtx, time.Second); err != nil {
+					return false
+				}
+				info.InfoDetail, err = getInfoDetail(parseCtx, client, info.DetailLink)
 				if err != nil {
-
-					return
+					return false
 				}
 				// Admin JPNIC Handle
 				if _, ok := isJPNICHandleExist[info.InfoDetail.TechJPNICHandle]; !ok {
 					// 一定時間停止
-					time.Sleep(1 * time.Second)
-					//log.Println("req2")
+					if err = sleepContext(parseCtx, time.Second); err != nil {
+						return false
+					}
 
-					jpnic, err := getJPNICHandle(client, info.InfoDetail.AdminJPNICHandleLink)
+					var jpnic JPNICHandleDetail
+					jpnic, err = getJPNICHandle(parseCtx, client, info.InfoDetail.AdminJPNICHandleLink)
 					if err != nil {
-						return
+						return false
 					}
 					jpnicHandles = append(jpnicHandles, jpnic)
 					isJPNICHandleExist[info.InfoDetail.TechJPNICHandle] = 0
 				}
 				// Tech JPNIC Handle
 				if _, ok := isJPNICHandleExist[info.InfoDetail.AdminJPNICHandle]; !ok {
-					//log.Println("req3")
 					// 一定時間停止
-					time.Sleep(1 * time.Second)
+					if err = sleepContext(parseCtx, time.Second); err != nil {
+						return false
+					}
 
-					jpnic, err := getJPNICHandle(client, info.InfoDetail.TechJPNICHandleLink)
+					var jpnic JPNICHandleDetail
+					jpnic, err = getJPNICHandle(parseCtx, client, info.InfoDetail.TechJPNICHandleLink)
 					if err != nil {
-						return
+						return false
 					}
 					jpnicHandles = append(jpnicHandles, jpnic)
 					isJPNICHandleExist[info.InfoDetail.AdminJPNICHandle] = 0
 				}
-				//log.Printf("count: %d\n", allCounter)
-				//log.Println("==========")
 			}
 			index = -1
 			if allCounter != 0 {
@@ -377,18 +446,36 @@ func (c *Config) SearchIPv4(search SearchIPv4) ([]InfoIPv4, []JPNICHandleDetail,
 			allCounter++
 		}
 		index++
+		return true
 	})
+	parseSpan.SetAttributes(Attr("jpnic.rows", len(infos)), Attr("jpnic.handles", len(jpnicHandles)))
+	endSpan(parseSpan, err)
 
-	return infos, jpnicHandles, nil
+	return infos, jpnicHandles, err
 }
 
 func (c *Config) SearchIPv6(search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail, error) {
-	client, menuURL, err := c.initAccess("登録情報検索(IPv6)")
+	return c.SearchIPv6Context(context.Background(), search)
+}
+
+// SearchIPv6Context はctxのキャンセル・スパンを引き継いでSearchIPv6を実行します。
+func (c *Config) SearchIPv6Context(ctx context.Context, search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail, error) {
+	ctx, span := c.startOperation(ctx, "SearchIPv6", Attr("jpnic.detail", search.IsDetail))
+	infos, handles, err := c.searchIPv6(ctx, search)
+	span.SetAttributes(Attr("jpnic.rows", len(infos)), Attr("jpnic.handles", len(handles)))
+	endSpan(span, err)
+	return infos, handles, err
+}
+
+func (c *Config) searchIPv6(ctx context.Context, search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail, error) {
+	client, menuURL, err := c.initAccess(ctx, "登録情報検索(IPv6)")
 	if err != nil {
 		return nil, nil, err
 	}
 
 	r := request{
+		Context:     ctx,
+		Step:        "form.fetch",
 		Client:      client,
 		URL:         baseURL + "/jpnic/" + menuURL,
 		Body:        "",
@@ -401,7 +488,7 @@ func (c *Config) SearchIPv6(search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail,
 		return nil, nil, err
 	}
 
-	resBody, _, err := readShiftJIS(resp.Body)
+	resBody, _, err := readHTML(resp)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -413,11 +500,11 @@ func (c *Config) SearchIPv6(search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail,
 
 	submitURL, isExists := doc.Find("form").Attr("action")
 	if !isExists {
-		return nil, nil, fmt.Errorf("submit URLが取得できませんでした")
+		return nil, nil, newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("submit URLが取得できませんでした"))
 	}
 	submitID, isExists := doc.Find("form").Find("input").Attr("value")
 	if !isExists {
-		return nil, nil, fmt.Errorf("inputフォームのIDが取得できませんでした")
+		return nil, nil, newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("inputフォームのIDが取得できませんでした"))
 	}
 
 	var requestStr string
@@ -433,7 +520,7 @@ func (c *Config) SearchIPv6(search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail,
 			}
 		})
 		if !isExists {
-			return nil, nil, fmt.Errorf("資源管理者略称が見つかりませんでした")
+			return nil, nil, newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("資源管理者略称が見つかりませんでした"))
 		}
 		requestStr = "destdisp=" + submitID
 		requestStr += "&ipaddr=" + ""
@@ -478,6 +565,8 @@ func (c *Config) SearchIPv6(search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail,
 	}
 
 	r = request{
+		Context:     ctx,
+		Step:        "form.submit",
 		Client:      client,
 		URL:         baseURL + submitURL,
 		Body:        reqBody,
@@ -490,7 +579,7 @@ func (c *Config) SearchIPv6(search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail,
 		return nil, nil, err
 	}
 
-	resBody, _, err = readShiftJIS(resp.Body)
+	resBody, _, err = readHTML(resp)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -507,10 +596,13 @@ func (c *Config) SearchIPv6(search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail,
 	index := 0
 	isJPNICHandleExist := make(map[string]int)
 
-	doc.Find("table").Children().Find("td").Each(func(_ int, tableHtml *goquery.Selection) {
+	// 一覧の解析(詳細情報の取得を含む)
+	parseCtx, parseSpan := startSpan(ctx, "parse")
+	// 詳細情報・JPNICハンドルの取得に失敗した場合は、その時点で解析を中断してエラーを返す
+	doc.Find("table").Children().Find("td").EachWithBreak(func(_ int, tableHtml *goquery.Selection) bool {
 		className, _ := tableHtml.Attr("class")
 		if className != "dataRow_mnt04" {
-			return
+			return true
 		}
 		dataStr := strings.TrimSpace(tableHtml.Text())
 		switch index {
@@ -541,42 +633,43 @@ func (c *Config) SearchIPv6(search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail,
 			info.KindID = dataStr
 			// 詳細情報の取得
 			if search.IsDetail && allCounter != 0 {
-				//log.Println("==========")
-				time.Sleep(1 * time.Second)
-				//log.Println("req1")
-				info.InfoDetail, err = getInfoDetail(client, info.DetailLink)
+				if err = sleepContext(parseCtx, time.Second); err != nil {
+					return false
+				}
+				info.InfoDetail, err = getInfoDetail(parseCtx, client, info.DetailLink)
 				if err != nil {
-
-					return
+					return false
 				}
 				// Admin JPNIC Handle
 				if _, ok := isJPNICHandleExist[info.InfoDetail.TechJPNICHandle]; !ok {
 					// 一定時間停止
-					time.Sleep(1 * time.Second)
-					//log.Println("req2")
+					if err = sleepContext(parseCtx, time.Second); err != nil {
+						return false
+					}
 
-					jpnic, err := getJPNICHandle(client, info.InfoDetail.AdminJPNICHandleLink)
+					var jpnic JPNICHandleDetail
+					jpnic, err = getJPNICHandle(parseCtx, client, info.InfoDetail.AdminJPNICHandleLink)
 					if err != nil {
-						return
+						return false
 					}
 					jpnicHandles = append(jpnicHandles, jpnic)
 					isJPNICHandleExist[info.InfoDetail.TechJPNICHandle] = 0
 				}
 				// Tech JPNIC Handle
 				if _, ok := isJPNICHandleExist[info.InfoDetail.AdminJPNICHandle]; !ok {
-					//log.Println("req3")
 					// 一定時間停止
-					time.Sleep(1 * time.Second)
+					if err = sleepContext(parseCtx, time.Second); err != nil {
+						return false
+					}
 
-					jpnic, err := getJPNICHandle(client, info.InfoDetail.TechJPNICHandleLink)
+					var jpnic JPNICHandleDetail
+					jpnic, err = getJPNICHandle(parseCtx, client, info.InfoDetail.TechJPNICHandleLink)
 					if err != nil {
-						return
+						return false
 					}
 					jpnicHandles = append(jpnicHandles, jpnic)
 					isJPNICHandleExist[info.InfoDetail.AdminJPNICHandle] = 0
 				}
-				//log.Printf("count: %d\n", allCounter)
-				//log.Println("==========")
 			}
 			index = -1
 			if allCounter != 0 {
@@ -586,20 +679,37 @@ func (c *Config) SearchIPv6(search SearchIPv6) ([]InfoIPv6, []JPNICHandleDetail,
 			allCounter++
 		}
 		index++
+		return true
 	})
+	parseSpan.SetAttributes(Attr("jpnic.rows", len(infos)), Attr("jpnic.handles", len(jpnicHandles)))
+	endSpan(parseSpan, err)
 
-	return infos, jpnicHandles, nil
+	return infos, jpnicHandles, err
 }
 
 func (c *Config) GetIPUser(userURL string) (InfoDetail, error) {
+	return c.GetIPUserContext(context.Background(), userURL)
+}
+
+// GetIPUserContext はctxのキャンセル・スパンを引き継いでGetIPUserを実行します。
+func (c *Config) GetIPUserContext(ctx context.Context, userURL string) (InfoDetail, error) {
+	ctx, span := c.startOperation(ctx, "GetIPUser")
+	info, err := c.getIPUser(ctx, userURL)
+	endSpan(span, err)
+	return info, err
+}
+
+func (c *Config) getIPUser(ctx context.Context, userURL string) (InfoDetail, error) {
 	var info InfoDetail
 
-	client, _, err := c.initAccess("担当グループ・JPNICハンドル検索／変換")
+	client, _, err := c.initAccess(ctx, "担当グループ・JPNICハンドル検索／変換")
 	if err != nil {
 		return info, err
 	}
 
 	r := request{
+		Context:     ctx,
+		Step:        "detail.fetch",
 		Client:      client,
 		URL:         baseURL + userURL,
 		Body:        "",
@@ -612,7 +722,7 @@ func (c *Config) GetIPUser(userURL string) (InfoDetail, error) {
 		return info, err
 	}
 
-	respBody, _, err := readShiftJIS(resp.Body)
+	respBody, _, err := readHTML(resp)
 	if err != nil {
 		return info, err
 	}
@@ -683,14 +793,28 @@ func (c *Config) GetIPUser(userURL string) (InfoDetail, error) {
 }
 
 func (c *Config) GetJPNICHandle(handle string) (JPNICHandleDetail, error) {
+	return c.GetJPNICHandleContext(context.Background(), handle)
+}
+
+// GetJPNICHandleContext はctxのキャンセル・スパンを引き継いでGetJPNICHandleを実行します。
+func (c *Config) GetJPNICHandleContext(ctx context.Context, handle string) (JPNICHandleDetail, error) {
+	ctx, span := c.startOperation(ctx, "GetJPNICHandle")
+	info, err := c.getJPNICHandle(ctx, handle)
+	endSpan(span, err)
+	return info, err
+}
+
+func (c *Config) getJPNICHandle(ctx context.Context, handle string) (JPNICHandleDetail, error) {
 	var info JPNICHandleDetail
 
-	client, menuURL, err := c.initAccess("登録情報検索(IPv6)")
+	client, menuURL, err := c.initAccess(ctx, "登録情報検索(IPv6)")
 	if err != nil {
 		return info, err
 	}
 
 	r := request{
+		Context:     ctx,
+		Step:        "form.fetch",
 		Client:      client,
 		URL:         baseURL + "/jpnic/" + menuURL,
 		Body:        "",
@@ -703,7 +827,7 @@ func (c *Config) GetJPNICHandle(handle string) (JPNICHandleDetail, error) {
 		return info, err
 	}
 
-	resBody, _, err := readShiftJIS(resp.Body)
+	resBody, _, err := readHTML(resp)
 	if err != nil {
 		return info, err
 	}
@@ -714,8 +838,10 @@ func (c *Config) GetJPNICHandle(handle string) (JPNICHandleDetail, error) {
 	}
 
 	r = request{
+		Context:     ctx,
+		Step:        "handle.fetch",
 		Client:      client,
-		URL:         baseURL + "/jpnic/entryinfo_handle.do?jpnic_hdl=" + handle,
+		URL:         baseURL + "/jpnic/entryinfo_handle.do?jpnic_hdl=" + url.QueryEscape(handle),
 		Body:        "",
 		UserAgent:   userAgent,
 		ContentType: contentType,
@@ -726,7 +852,7 @@ func (c *Config) GetJPNICHandle(handle string) (JPNICHandleDetail, error) {
 		return info, err
 	}
 
-	resBody, _, err = readShiftJIS(resp.Body)
+	resBody, _, err = readHTML(resp)
 	if err != nil {
 		return info, err
 	}
@@ -1290,12 +1416,28 @@ func (c *Config) GetJPNICHandle(handle string) (JPNICHandleDetail, error) {
 //}
 
 func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
-	client, menuURL, err := c.initAccess("担当グループ（担当者）情報登録・変更")
+	return c.ChangeUserInfoContext(context.Background(), input)
+}
+
+// ChangeUserInfoContext はctxのキャンセル・スパンを引き継いでChangeUserInfoを実行します。
+func (c *Config) ChangeUserInfoContext(ctx context.Context, input JPNICHandleInput) (string, error) {
+	ctx, span := c.startOperation(ctx, "ChangeUserInfo")
+	recepNo, err := c.submitHandle(ctx, input, false)
+	endSpan(span, err)
+	return recepNo, err
+}
+
+// submitHandle は担当グループ（担当者）情報登録・変更の申請を行い、受付番号を返します。
+// registerの場合はJPNICハンドルを指定せずに新規登録します。
+func (c *Config) submitHandle(ctx context.Context, input JPNICHandleInput, register bool) (string, error) {
+	client, menuURL, err := c.initAccess(ctx, "担当グループ（担当者）情報登録・変更")
 	if err != nil {
 		return "", err
 	}
 
 	r := request{
+		Context:     ctx,
+		Step:        "form.fetch",
 		Client:      client,
 		URL:         baseURL + "/jpnic/" + menuURL,
 		Body:        "",
@@ -1308,7 +1450,7 @@ func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
 		return "", err
 	}
 
-	resBody, _, err := readShiftJIS(resp.Body)
+	resBody, _, err := readHTML(resp)
 	if err != nil {
 		return "", err
 	}
@@ -1345,7 +1487,7 @@ func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
 	})
 
 	if actionURL == "" {
-		return "", fmt.Errorf("action URLの取得失敗")
+		return "", newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("action URLの取得失敗"))
 	}
 
 	// 初期値はJPNIC Handleで指定していた場合を想定
@@ -1355,26 +1497,29 @@ func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
 		kind = "group"
 	}
 
-	str := "org.apache.struts.taglib.html.TOKEN=" + token + "&destdisp=" + destDisp + "&aplyid=" + aplyId +
-		"&kind=" + kind + "&jpnic_hdl=" + input.JPNICHandle +
-		"&name_jp=" + input.Name + "&name=" + input.NameEn + "&email=" + input.Email +
-		"&org_nm_jp=" + input.Org + "&org_nm=" + input.OrgEn +
-		"&zipcode=" + input.ZipCode + "&addr_jp=" + input.Address + "&addr=" + input.AddressEn +
-		"&division_jp=" + input.Division + "&division=" + input.DivisionEn +
-		"&title_jp=" + input.Title + "&title=" + input.TitleEn +
-		"&phone=" + input.Tel + "&fax=" + input.Fax + "&ntfy_mail=" + input.NotifyMail +
-		"&aply_from_addr=" + input.ApplyMail + "&aply_from_addr_confirm=" + input.ApplyMail + "&action=%90%5C%90%BF"
-
-	// utf-8 => shift-jis
-	reqBody, _, err := toShiftJIS(str)
+	pairs := []string{"org.apache.struts.taglib.html.TOKEN", token, "destdisp", destDisp, "aplyid", aplyId, "kind", kind}
+	if !register {
+		pairs = append(pairs, "jpnic_hdl", input.JPNICHandle)
+	}
+	// 値に&・=・%等が含まれる場合があるため、Shift_JISに変換した上でエスケープする
+	str, err := encodeForm(append(pairs,
+		"name_jp", input.Name, "name", input.NameEn, "email", input.Email,
+		"org_nm_jp", input.Org, "org_nm", input.OrgEn,
+		"zipcode", input.ZipCode, "addr_jp", input.Address, "addr", input.AddressEn,
+		"division_jp", input.Division, "division", input.DivisionEn,
+		"title_jp", input.Title, "title", input.TitleEn,
+		"phone", input.Tel, "fax", input.Fax, "ntfy_mail", input.NotifyMail,
+		"aply_from_addr", input.ApplyMail, "aply_from_addr_confirm", input.ApplyMail, "action", "申請")...)
 	if err != nil {
 		return "", err
 	}
 
 	r = request{
+		Context:     ctx,
+		Step:        "form.submit",
 		Client:      client,
 		URL:         baseURL + actionURL,
-		Body:        reqBody,
+		Body:        str,
 		UserAgent:   userAgent,
 		ContentType: contentType,
 	}
@@ -1385,7 +1530,7 @@ func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
 	}
 
 	// utf-8 => shift-jis
-	resBody, _, err = readShiftJIS(resp.Body)
+	resBody, _, err = readHTML(resp)
 	if err != nil {
 		return "", err
 	}
@@ -1427,7 +1572,7 @@ func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
 	})
 
 	if actionURL == "" {
-		return "", fmt.Errorf("action URLの取得失敗")
+		return "", newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("action URLの取得失敗"))
 	}
 
 	if !strings.Contains(resBody, "上記の申請内容でよろしければ、「確認」ボタンを押してください。") {
@@ -1446,18 +1591,18 @@ func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
 
 	}
 
-	str = "org.apache.struts.taglib.html.TOKEN=" + token + "&prevDispId=" + prevDispId + "&aplyid=" + aplyId +
-		"&destdisp=" + destDisp + "&inputconf=%8Am%94F"
-	// utf-8 => shift-jis
-	reqBody, _, err = toShiftJIS(str)
+	str, err = encodeForm("org.apache.struts.taglib.html.TOKEN", token, "prevDispId", prevDispId, "aplyid", aplyId,
+		"destdisp", destDisp, "inputconf", "確認")
 	if err != nil {
 		return "", err
 	}
 
 	r = request{
+		Context:     ctx,
+		Step:        "form.confirm",
 		Client:      client,
 		URL:         baseURL + actionURL,
-		Body:        reqBody,
+		Body:        str,
 		UserAgent:   userAgent,
 		ContentType: contentType,
 	}
@@ -1469,7 +1614,7 @@ func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
 	defer resp.Body.Close()
 
 	// utf-8 => shift-jis
-	resBody, _, err = readShiftJIS(resp.Body)
+	resBody, _, err = readHTML(resp)
 	if err != nil {
 		return "", err
 	}
@@ -1481,10 +1626,10 @@ func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
 
 	var recepNo string
 
-	// actionのURLを取得
+	// 受付番号を取得
 	doc.Find("table").Children().Find("table").Children().Find("td").Each(func(_ int, tableHtml1 *goquery.Selection) {
 		if strings.Contains(tableHtml1.Prev().Text(), "受付番号") {
-			recepNo = tableHtml1.Text()
+			recepNo = strings.TrimSpace(tableHtml1.Text())
 		}
 	})
 
@@ -1492,12 +1637,97 @@ func (c *Config) ChangeUserInfo(input JPNICHandleInput) (string, error) {
 }
 
 func (c *Config) GetRequestList(searchStr string) ([]RequestInfo, error) {
-	client, menuURL, err := c.initAccess("申請一覧")
+	return c.SearchRequestList(RequestSearch{StartRecepNo: searchStr})
+}
+
+// GetRequestListContext はctxのキャンセル・スパンを引き継いでGetRequestListを実行します。
+func (c *Config) GetRequestListContext(ctx context.Context, searchStr string) ([]RequestInfo, error) {
+	return c.SearchRequestListContext(ctx, RequestSearch{StartRecepNo: searchStr})
+}
+
+func (c *Config) SearchRequestList(search RequestSearch) ([]RequestInfo, error) {
+	return c.SearchRequestListContext(context.Background(), search)
+}
+
+// SearchRequestListContext はctxのキャンセル・スパンを引き継いでSearchRequestListを実行します。
+func (c *Config) SearchRequestListContext(ctx context.Context, search RequestSearch) ([]RequestInfo, error) {
+	ctx, span := c.startOperation(ctx, "SearchRequestList")
+	infos, err := c.searchRequestList(ctx, search)
+	span.SetAttributes(Attr("jpnic.rows", len(infos)))
+	endSpan(span, err)
+	return infos, err
+}
+
+func (c *Config) searchRequestList(ctx context.Context, search RequestSearch) ([]RequestInfo, error) {
+	client, menuURL, err := c.initAccess(ctx, "申請一覧")
 	if err != nil {
 		return nil, err
 	}
 
+	return searchRequestList(ctx, client, menuURL, search)
+}
+
+// GetRequestDetail は申請一覧から受付番号の申請を探し、申請情報(詳細)を取得します。
+func (c *Config) GetRequestDetail(recepNo string) (RequestDetail, error) {
+	return c.GetRequestDetailContext(context.Background(), recepNo)
+}
+
+// GetRequestDetailContext はctxのキャンセル・スパンを引き継いでGetRequestDetailを実行します。
+func (c *Config) GetRequestDetailContext(ctx context.Context, recepNo string) (RequestDetail, error) {
+	ctx, span := c.startOperation(ctx, "GetRequestDetail")
+	detail, err := c.getRequestDetail(ctx, recepNo)
+	endSpan(span, err)
+	return detail, err
+}
+
+func (c *Config) getRequestDetail(ctx context.Context, recepNo string) (RequestDetail, error) {
+	var detail RequestDetail
+
+	recepNo = strings.TrimSpace(recepNo)
+	if recepNo == "" {
+		return detail, fmt.Errorf("受付番号が指定されていません")
+	}
+
+	client, menuURL, err := c.initAccess(ctx, "申請一覧")
+	if err != nil {
+		return detail, err
+	}
+
+	infos, err := searchRequestList(ctx, client, menuURL, RequestSearch{StartRecepNo: recepNo, EndRecepNo: recepNo})
+	if err != nil {
+		return detail, err
+	}
+
+	info, ok := findRequest(infos, recepNo)
+	if !ok {
+		return detail, fmt.Errorf("受付番号 %s の申請が見つかりません", recepNo)
+	}
+	if info.DetailLink == "" {
+		return detail, fmt.Errorf("受付番号 %s の申請情報(詳細)へのリンクが見つかりません", recepNo)
+	}
+
+	detail, err = getRecepDetail(ctx, client, info.DetailLink)
+	if err != nil {
+		return detail, err
+	}
+
+	// 詳細画面に表示されない項目は一覧の値で補う
+	fillString(&detail.RecepNo, info.RecepNo)
+	fillString(&detail.DeliNo, info.DeliNo)
+	fillString(&detail.ApplyKind, info.ApplyKind)
+	fillString(&detail.ApplyClass, info.ApplyClass)
+	fillString(&detail.Applicant, info.Applicant)
+	fillString(&detail.ApplyDate, info.ApplyDate)
+	fillString(&detail.CompleteDate, info.CompleteDate)
+	fillString(&detail.Status, info.Status)
+
+	return detail, nil
+}
+
+func searchRequestList(ctx context.Context, client *http.Client, menuURL string, search RequestSearch) ([]RequestInfo, error) {
 	r := request{
+		Context:     ctx,
+		Step:        "form.fetch",
 		Client:      client,
 		URL:         baseURL + "/jpnic/" + menuURL,
 		Body:        "",
@@ -1509,8 +1739,9 @@ func (c *Config) GetRequestList(searchStr string) ([]RequestInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	resBody, _, err := readShiftJIS(resp.Body)
+	resBody, _, err := readHTML(resp)
 	if err != nil {
 		return nil, err
 	}
@@ -1534,14 +1765,27 @@ func (c *Config) GetRequestList(searchStr string) ([]RequestInfo, error) {
 		})
 	})
 
-	str := "destdisp=" + destDisp + "&startRecepNo=" + searchStr + "&endRecepNo=&deliNo=&aplyKind=&aplyClass=&resceAdmSnm=&aplyDateS=&aplyDateE=&completDateS=&completDateE=&statusId=&pswdResceNewConfirm=%81%40%8C%9F%8D%F5%81%40"
+	if actionURL == "" {
+		return nil, newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("action URLの取得失敗"))
+	}
+
 	// utf-8 => shift-jis
-	reqBody, _, err := toShiftJIS(str)
+	reqBody, err := encodeForm(
+		"destdisp", destDisp,
+		"startRecepNo", search.StartRecepNo, "endRecepNo", search.EndRecepNo,
+		"deliNo", search.DeliNo, "aplyKind", search.ApplyKind, "aplyClass", search.ApplyClass,
+		"resceAdmSnm", search.Ryakusho,
+		"aplyDateS", search.ApplyStart, "aplyDateE", search.ApplyEnd,
+		"completDateS", search.CompleteStart, "completDateE", search.CompleteEnd,
+		"statusId", search.StatusID, "pswdResceNewConfirm", "　検索　",
+	)
 	if err != nil {
 		return nil, err
 	}
 
 	r = request{
+		Context:     ctx,
+		Step:        "form.submit",
 		Client:      client,
 		URL:         baseURL + actionURL,
 		Body:        reqBody,
@@ -1553,59 +1797,90 @@ func (c *Config) GetRequestList(searchStr string) ([]RequestInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	resBody, _, err = readShiftJIS(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	doc, err = goquery.NewDocumentFromReader(strings.NewReader(resBody))
+	resBody, _, err = readHTML(resp)
 	if err != nil {
 		return nil, err
 	}
 
-	//count := 0
 	var infos []RequestInfo
-	var info RequestInfo
+	visited := make(map[string]bool)
 
-	doc.Find("table").Children().Find("td").Each(func(_ int, tableHtml *goquery.Selection) {
-		dataStr := strings.TrimSpace(tableHtml.Text())
-		switch tableHtml.Index() {
-		case 0:
-			info.RecepNo = dataStr
-		case 1:
-			info.DeliNo = dataStr
-		case 2:
-			info.ApplyKind = dataStr
-		case 3:
-			info.ApplyClass = dataStr
-		case 4:
-			info.Applicant = dataStr
-		case 5:
-			info.ApplyDate = dataStr
-		case 6:
-			info.CompleteDate = dataStr
-		case 7:
-			info.Status = dataStr
-			infos = append(infos, info)
-			info = RequestInfo{}
+	for page := 1; ; page++ {
+		doc, err = goquery.NewDocumentFromReader(strings.NewReader(resBody))
+		if err != nil {
+			return nil, err
 		}
-	})
 
-	infos = infos[1:]
+		_, parseSpan := startSpan(ctx, "parse", Attr("jpnic.page", page))
+		rows := parseRequestList(doc)
+		parseSpan.SetAttributes(Attr("jpnic.rows", len(rows)))
+		endSpan(parseSpan, nil)
+		if page == 1 && len(rows) == 0 {
+			captureEmpty(ctx, "parse", r.URL, resBody)
+		}
+		infos = append(infos, rows...)
+
+		if search.MaxPages != 0 && page >= search.MaxPages {
+			break
+		}
+
+		// 次ページ
+		nextURL := getNextPageLink(doc)
+		if nextURL == "" || visited[nextURL] {
+			break
+		}
+		visited[nextURL] = true
+
+		r = request{
+			Context:     ctx,
+			Step:        "page.fetch",
+			Client:      client,
+			URL:         nextURL,
+			UserAgent:   userAgent,
+			ContentType: contentType,
+		}
+
+		resp, err = r.get()
+		if err != nil {
+			return nil, err
+		}
+
+		// ページ毎に閉じる(deferでは関数の終了まで接続が解放されない)
+		resBody, _, err = readHTML(resp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return infos, nil
 }
 
 func (c *Config) GetResourceManagement() (ResourceInfo, string, error) {
+	return c.GetResourceManagementContext(context.Background())
+}
+
+// GetResourceManagementContext はctxのキャンセル・スパンを引き継いでGetResourceManagementを実行します。
+func (c *Config) GetResourceManagementContext(ctx context.Context) (ResourceInfo, string, error) {
+	ctx, span := c.startOperation(ctx, "GetResourceManagement")
+	info, html, err := c.getResourceManagement(ctx)
+	endSpan(span, err)
+	return info, html, err
+}
+
+func (c *Config) getResourceManagement(ctx context.Context) (ResourceInfo, string, error) {
 	var info ResourceInfo
 	var html string
-	client, menuURL, err := c.initAccess("資源管理者情報")
+	client, menuURL, err := c.initAccess(ctx, "資源管理者情報")
 	if err != nil {
 		return info, html, err
 	}
 
 	r := request{
+		Context:     ctx,
+		Step:        "form.fetch",
 		Client:      client,
 		URL:         baseURL + "/jpnic/" + menuURL,
 		Body:        "",
@@ -1618,7 +1893,7 @@ func (c *Config) GetResourceManagement() (ResourceInfo, string, error) {
 		return info, html, err
 	}
 
-	resBody, _, err := readShiftJIS(resp.Body)
+	resBody, _, err := readHTML(resp)
 	if err != nil {
 		return info, html, err
 	}
@@ -1637,6 +1912,7 @@ func (c *Config) GetResourceManagement() (ResourceInfo, string, error) {
 	cidrBlockSegment := false
 	var cidrBlock ResourceCIDRBlock
 
+	_, parseSpan := startSpan(ctx, "parse")
 	doc.Find("table").Children().Find("table").Children().Find("table").Children().Find("table").Children().Find("td").Each(func(_ int, tableHtml1 *goquery.Selection) {
 		dataStr := strings.TrimSpace(tableHtml1.Text())
 		index := tableHtml1.Index()
@@ -1719,8 +1995,6 @@ func (c *Config) GetResourceManagement() (ResourceInfo, string, error) {
 					break
 				}
 			case "ＡＤ　ｒａｔｉｏ":
-				log.Println(strconv.Itoa(index) + ": " + dataStr)
-
 				info.ADRatio, err = strconv.ParseFloat(dataStr, 16)
 				if err != nil {
 					break
@@ -1754,9 +2028,11 @@ func (c *Config) GetResourceManagement() (ResourceInfo, string, error) {
 			info.ResourceCIDRBlock = append(info.ResourceCIDRBlock, cidrBlock)
 		}
 	})
+	parseSpan.SetAttributes(Attr("jpnic.rows", len(info.ResourceCIDRBlock)))
+	endSpan(parseSpan, err)
 
 	if err != nil {
-		return info, html, err
+		return info, html, newParseError(ctx, "parse", r.URL, resBody, err)
 	}
 	return info, html, nil
 }
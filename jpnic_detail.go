@@ -1,16 +1,19 @@
 package jpnic
 
 import (
+	"context"
+	"fmt"
 	"github.com/PuerkitoBio/goquery"
-	"log"
 	"net/http"
 	"strings"
 )
 
-func getInfoDetail(client *http.Client, userURL string) (InfoDetail, error) {
+func getInfoDetail(ctx context.Context, client *http.Client, userURL string) (InfoDetail, error) {
 	var info InfoDetail
 
 	r := request{
+		Context:     ctx,
+		Step:        "detail.fetch",
 		Client:      client,
 		URL:         baseURL + userURL,
 		Body:        "",
@@ -20,11 +23,11 @@ func getInfoDetail(client *http.Client, userURL string) (InfoDetail, error) {
 
 	resp, err := r.get()
 	if err != nil {
-		log.Println(err)
 		return info, err
 	}
+	defer resp.Body.Close()
 
-	respBody, _, err := readShiftJIS(resp.Body)
+	respBody, _, err := readHTML(resp)
 	if err != nil {
 		return info, err
 	}
@@ -36,6 +39,8 @@ func getInfoDetail(client *http.Client, userURL string) (InfoDetail, error) {
 
 	var title string
 	isTitle := true
+	// 値を取得できた項目の数
+	parsed := 0
 
 	doc.Find("table").Children().Find("table").Children().Find("table").Children().Find("table").Children().Find("td").Each(func(_ int, tableHtml1 *goquery.Selection) {
 		dataStr := strings.TrimSpace(tableHtml1.Text())
@@ -43,6 +48,7 @@ func getInfoDetail(client *http.Client, userURL string) (InfoDetail, error) {
 			title = dataStr
 		}
 
+		matched := true
 		switch title {
 		case "IPネットワークアドレス":
 			info.IPAddress = dataStr
@@ -86,18 +92,32 @@ func getInfoDetail(client *http.Client, userURL string) (InfoDetail, error) {
 			info.ReturnDate = dataStr
 		case "最終更新":
 			info.UpdateDate = dataStr
+		default:
+			matched = false
+		}
+		if matched && !isTitle && dataStr != "" {
+			parsed++
 		}
 
 		isTitle = !isTitle
 	})
 
-	return info, err
+	if info.IPAddress == "" {
+		return info, newParseError(ctx, r.Step, r.URL, respBody, fmt.Errorf("IPネットワークアドレスが見つかりませんでした"))
+	}
+	if parsed <= 1 {
+		captureEmpty(ctx, r.Step, r.URL, respBody)
+	}
+
+	return info, nil
 }
 
-func getJPNICHandle(client *http.Client, handleURL string) (JPNICHandleDetail, error) {
+func getJPNICHandle(ctx context.Context, client *http.Client, handleURL string) (JPNICHandleDetail, error) {
 	var info JPNICHandleDetail
 
 	r := request{
+		Context:     ctx,
+		Step:        "handle.fetch",
 		Client:      client,
 		URL:         baseURL + "/jpnic/" + handleURL,
 		Body:        "",
@@ -109,8 +129,9 @@ func getJPNICHandle(client *http.Client, handleURL string) (JPNICHandleDetail, e
 	if err != nil {
 		return info, err
 	}
+	defer resp.Body.Close()
 
-	resBody, _, err := readShiftJIS(resp.Body)
+	resBody, _, err := readHTML(resp)
 	if err != nil {
 		return info, err
 	}
@@ -122,6 +143,8 @@ func getJPNICHandle(client *http.Client, handleURL string) (JPNICHandleDetail, e
 
 	var title string
 	isTitle := true
+	// 値を取得できた項目の数
+	parsed := 0
 
 	doc.Find("table").Children().Find("table").Children().Find("table").Children().Find("td").Each(func(_ int, tableHtml1 *goquery.Selection) {
 		dataStr := strings.TrimSpace(tableHtml1.Text())
@@ -129,6 +152,7 @@ func getJPNICHandle(client *http.Client, handleURL string) (JPNICHandleDetail, e
 			title = dataStr
 		}
 
+		matched := true
 		switch title {
 		case "グループハンドル":
 			info.IsJPNICHandle = false
@@ -170,46 +194,156 @@ func getJPNICHandle(client *http.Client, handleURL string) (JPNICHandleDetail, e
 			info.NotifyAddress = dataStr
 		case "最終更新":
 			info.UpdateDate = dataStr
+		default:
+			matched = false
+		}
+		if matched && !isTitle && dataStr != "" {
+			parsed++
 		}
 
 		isTitle = !isTitle
 	})
 
-	return info, err
+	if info.JPNICHandle == "" {
+		return info, newParseError(ctx, r.Step, r.URL, resBody, fmt.Errorf("JPNICハンドルが見つかりませんでした"))
+	}
+	if parsed <= 1 {
+		captureEmpty(ctx, r.Step, r.URL, resBody)
+	}
+
+	return info, nil
 }
 
-func getRecepDetail(client *http.Client, recepURL string) (string, error) {
+func getRecepDetail(ctx context.Context, client *http.Client, recepURL string) (RequestDetail, error) {
+	var detail RequestDetail
+
 	r := request{
+		Context:     ctx,
+		Step:        "detail.fetch",
 		Client:      client,
-		URL:         baseURL + recepURL,
+		URL:         resolveURL(recepURL),
 		UserAgent:   userAgent,
 		ContentType: contentType,
 	}
 
 	resp, err := r.get()
 	if err != nil {
-		return "", err
+		return detail, err
 	}
 	defer resp.Body.Close()
 
-	body, _, err := readShiftJIS(resp.Body)
+	body, _, err := readHTML(resp)
 	if err != nil {
-		return "", err
+		return detail, err
 	}
 
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
 	if err != nil {
-		return "", err
+		return detail, err
 	}
 
-	var info string
+	detail = parseRecepDetail(doc)
+	// 受付番号等は詳細画面に表示されない場合があるため、何も取得できなかった場合のみエラーとする
+	if detail.RecepNo == "" && detail.Status == "" && len(detail.Fields) == 0 && len(detail.History) == 0 {
+		return detail, newParseError(ctx, r.Step, r.URL, body, fmt.Errorf("申請情報(詳細)が見つかりませんでした"))
+	}
+	if len(detail.Fields) == 0 {
+		captureEmpty(ctx, r.Step, r.URL, body)
+	}
 
-	doc.Find("table").Children().Find("table").Children().Find("td").Each(func(index int, tableHtml1 *goquery.Selection) {
-		dataStr := strings.TrimSpace(tableHtml1.Text())
-		if dataStr != "" {
-			info = "\n" + dataStr
-		}
+	return detail, nil
+}
+
+// parseRecepDetail は申請情報(詳細)の画面を解析します。
+// 項目名と値の2列の行は申請内容、「処理日」「ステータス」を見出しに持つ表は処理履歴として扱います。
+func parseRecepDetail(doc *goquery.Document) RequestDetail {
+	var detail RequestDetail
+
+	doc.Find("table").Each(func(_ int, tableHtml *goquery.Selection) {
+		isHistory := false
+		dateIndex, statusIndex, noteIndex := -1, -1, -1
+
+		tableHtml.ChildrenFiltered("tbody").AddSelection(tableHtml).ChildrenFiltered("tr").Each(func(_ int, rowHtml *goquery.Selection) {
+			// レイアウト用の入れ子の表は、内側の表で処理する
+			if rowHtml.Find("table").Length() != 0 {
+				return
+			}
+
+			var cells []string
+			rowHtml.ChildrenFiltered("td,th").Each(func(_ int, cellHtml *goquery.Selection) {
+				cells = append(cells, strings.TrimSpace(cellHtml.Text()))
+			})
+			if len(cells) == 0 {
+				return
+			}
+
+			// 処理履歴の見出し
+			if !isHistory && len(cells) >= 2 {
+				for index, cell := range cells {
+					switch {
+					case strings.Contains(cell, "処理日"), cell == "日時", cell == "日付":
+						dateIndex = index
+					case strings.Contains(cell, "ステータス"), cell == "状態", cell == "処理内容":
+						statusIndex = index
+					case strings.Contains(cell, "備考"), strings.Contains(cell, "コメント"):
+						noteIndex = index
+					}
+				}
+				if dateIndex != -1 && statusIndex != -1 {
+					isHistory = true
+					return
+				}
+				dateIndex, statusIndex, noteIndex = -1, -1, -1
+			}
+
+			if isHistory {
+				var history RequestHistory
+				if dateIndex < len(cells) {
+					history.Date = cells[dateIndex]
+				}
+				if statusIndex < len(cells) {
+					history.Status = cells[statusIndex]
+				}
+				if noteIndex != -1 && noteIndex < len(cells) {
+					history.Note = cells[noteIndex]
+				}
+				if history.Date != "" || history.Status != "" {
+					detail.History = append(detail.History, history)
+				}
+				return
+			}
+
+			if len(cells) != 2 || cells[0] == "" {
+				return
+			}
+
+			title, dataStr := cells[0], cells[1]
+			switch {
+			case title == "受付番号":
+				detail.RecepNo = dataStr
+			case title == "審議番号":
+				detail.DeliNo = dataStr
+			case title == "申請種別":
+				detail.ApplyKind = dataStr
+			case title == "申請区分":
+				detail.ApplyClass = dataStr
+			case title == "申請者", title == "申請者名":
+				detail.Applicant = dataStr
+			case title == "申請日", title == "申請日時":
+				detail.ApplyDate = dataStr
+			case title == "完了日", title == "完了日時":
+				detail.CompleteDate = dataStr
+			case title == "ステータス", title == "状態":
+				detail.Status = dataStr
+			case strings.Contains(title, "備考"), strings.Contains(title, "連絡事項"), strings.Contains(title, "JPNICからの"):
+				if dataStr != "" {
+					detail.Remarks = append(detail.Remarks, dataStr)
+				}
+			default:
+				detail.Fields = append(detail.Fields, RequestDetailField{Name: title, Value: dataStr})
+			}
+		})
 	})
 
-	return info, nil
+	return detail
 }
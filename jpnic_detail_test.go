@@ -0,0 +1,93 @@
+package jpnic
+
+import (
+	"context"
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRecepDetail(t *testing.T) {
+	html := `<html><body><table><tr><td>
+<table>
+<tr><td>受付番号</td><td>020210901000001</td></tr>
+<tr><td>申請種別</td><td>IPv4割り当て報告</td></tr>
+<tr><td>申請者</td><td>山田 太郎</td></tr>
+<tr><td>IPネットワークアドレス</td><td>192.0.2.0/24</td></tr>
+<tr><td>ネットワーク名</td><td>EXAMPLE-NET</td></tr>
+<tr><td>JPNICからの連絡事項</td><td>組織名の表記を修正しました</td></tr>
+</table>
+<table>
+<tr><th>処理日</th><th>ステータス</th><th>備考</th></tr>
+<tr><td>2021/09/01</td><td>受付</td><td></td></tr>
+<tr><td>2021/09/02</td><td>完了</td><td>登録しました</td></tr>
+</table>
+</td></tr></table></body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	detail := parseRecepDetail(doc)
+
+	if detail.RecepNo != "020210901000001" || detail.ApplyKind != "IPv4割り当て報告" || detail.Applicant != "山田 太郎" {
+		t.Fatalf("detail: %+v", detail)
+	}
+	if len(detail.Fields) != 2 || detail.Fields[1] != (RequestDetailField{"ネットワーク名", "EXAMPLE-NET"}) {
+		t.Fatalf("fields: %+v", detail.Fields)
+	}
+	if len(detail.Remarks) != 1 {
+		t.Fatalf("remarks: %+v", detail.Remarks)
+	}
+	if len(detail.History) != 2 || detail.History[1] != (RequestHistory{"2021/09/02", "完了", "登録しました"}) {
+		t.Fatalf("history: %+v", detail.History)
+	}
+}
+
+func TestDetailParseError(t *testing.T) {
+	pages := map[string]string{
+		"/jpnic/maintenance.do": `<html><body><p>ただいま混み合っています</p></body></html>`,
+		"/jpnic/handle.do": `<table><tr><td><table><tr><td><table>` +
+			`<tr><td>JPNICハンドル</td><td>YY38053JP</td></tr>` +
+			`</table></td></tr></table></td></tr></table>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=Shift_JIS")
+		w.Write(toShiftJISTest(t, pages[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = srv.URL
+
+	dir := t.TempDir()
+	ctx, span := (&Config{CaptureDir: dir}).startOperation(context.Background(), "GetIPUser")
+	defer endSpan(span, nil)
+
+	// 想定と異なるページはParseErrorを返し、ページを保存する
+	var parseErr *ParseError
+	if _, err := getInfoDetail(ctx, srv.Client(), "/jpnic/maintenance.do"); !errors.As(err, &parseErr) || parseErr.Step != "detail.fetch" || parseErr.Path == "" {
+		t.Fatalf("info: %v", err)
+	}
+	if _, err := getJPNICHandle(ctx, srv.Client(), "maintenance.do"); !errors.As(err, &parseErr) || parseErr.Step != "handle.fetch" {
+		t.Fatalf("handle: %v", err)
+	}
+	if _, err := getRecepDetail(ctx, srv.Client(), srv.URL+"/jpnic/maintenance.do"); !errors.As(err, &parseErr) || parseErr.Step != "detail.fetch" {
+		t.Fatalf("recep: %v", err)
+	}
+
+	// JPNICハンドル以外の項目が無い場合はエラーにせずページを保存する
+	info, err := getJPNICHandle(ctx, srv.Client(), "handle.do")
+	if err != nil || info.JPNICHandle != "YY38053JP" {
+		t.Fatalf("%+v %v", info, err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*-GetIPUser-handle.fetch.html"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("%v %v", files, err)
+	}
+}
@@ -0,0 +1,153 @@
+package jpnic
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LogLevel はログの重要度です。
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Logger は構造化ログの出力先です。keyvalsはキーと値を交互に並べたものです。
+// Configに設定しない場合、ライブラリはログを出力しません。
+// 文字列の値はLoggerに渡される前に、パスワード・TOKEN・メールアドレス・電話番号がマスクされます。
+// error・time.Duration等の文字列以外の値はそのまま渡されます。(キー名がパスワード等の場合は型に関わらずマスクされます)
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// LoggerFunc は関数をLoggerとして使うための型です。
+type LoggerFunc func(level LogLevel, msg string, keyvals ...interface{})
+
+func (f LoggerFunc) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	f(level, msg, keyvals...)
+}
+
+// NewLogger はlog.Loggerに"level=debug msg=... key=value"の形式で出力するLoggerを返します。
+// minLevel未満のログは出力しません。
+func NewLogger(out *log.Logger, minLevel LogLevel) Logger {
+	return LoggerFunc(func(level LogLevel, msg string, keyvals ...interface{}) {
+		if level < minLevel {
+			return
+		}
+
+		var b strings.Builder
+		b.WriteString("level=" + level.String() + " msg=" + strconv.Quote(msg))
+		for i := 0; i < len(keyvals); i += 2 {
+			var value interface{} = "(MISSING)"
+			if i+1 < len(keyvals) {
+				value = keyvals[i+1]
+			}
+			str := fmt.Sprint(value)
+			if strings.ContainsAny(str, " \"=") || str == "" {
+				str = strconv.Quote(str)
+			}
+			fmt.Fprintf(&b, " %v=%s", keyvals[i], str)
+		}
+		out.Print(b.String())
+	})
+}
+
+// nopLogger はLoggerが設定されていない場合に使います。
+type nopLogger struct{}
+
+func (nopLogger) Log(LogLevel, string, ...interface{}) {}
+
+// logger はConfigのLoggerを、パスワード等をマスクするLoggerで包んで返します。secretsは追加でマスクする値です。
+func (c *Config) logger(secrets ...string) Logger {
+	if c.Logger == nil {
+		return nopLogger{}
+	}
+
+	r := c.redactor(secrets...)
+	r.base = c.Logger
+	return r
+}
+
+// redactor はConfigのパスワードとsecretsをマスクするredactLoggerを返します。
+func (c *Config) redactor(secrets ...string) *redactLogger {
+	r := &redactLogger{}
+	for _, secret := range append([]string{c.PfxPass, c.KeyPass}, secrets...) {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	return r
+}
+
+const redacted = "[REDACTED]"
+
+var (
+	// Strutsのトランザクショントークン(org.apache.struts.taglib.html.TOKEN)とセッションID
+	tokenRegexp = regexp.MustCompile(`(?i)(TOKEN|jsessionid)(["']?\s*[=:]\s*["']?|"\s+value=")[^&;"'\s]+`)
+	emailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)+`)
+	// 03-1234-5678, 090(1234)5678, +81-3-1234-5678, 0312345678 等
+	phoneRegexp = regexp.MustCompile(`(\+\d{1,3}[- ]?|\b0)\d{1,4}[-( ]\d{1,4}[-) ]\d{3,4}\b|\b0\d{9,10}\b`)
+)
+
+// sensitiveKey はキーの名前から値全体をマスクすべきかを判定します。
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"pass", "pswd", "secret", "token", "pin"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactLogger は値をマスクしてから元のLoggerに渡します。
+type redactLogger struct {
+	base    Logger
+	secrets []string
+}
+
+func (r *redactLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	values := make([]interface{}, len(keyvals))
+	for i, value := range keyvals {
+		if i%2 == 1 && sensitiveKey(fmt.Sprint(keyvals[i-1])) {
+			values[i] = redacted
+			continue
+		}
+
+		if str, ok := value.(string); ok {
+			values[i] = r.redact(str)
+		} else {
+			values[i] = value
+		}
+	}
+	r.base.Log(level, r.redact(msg), values...)
+}
+
+func (r *redactLogger) redact(str string) string {
+	for _, secret := range r.secrets {
+		str = strings.ReplaceAll(str, secret, redacted)
+	}
+	str = tokenRegexp.ReplaceAllString(str, "${1}${2}"+redacted)
+	str = emailRegexp.ReplaceAllString(str, redacted)
+	return phoneRegexp.ReplaceAllString(str, redacted)
+}
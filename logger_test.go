@@ -0,0 +1,105 @@
+package jpnic
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errLogTest = errors.New("dial tcp 192.0.2.1:443: connection refused")
+
+type testLogEntry struct {
+	level   LogLevel
+	msg     string
+	keyvals []interface{}
+}
+
+func (e testLogEntry) value(key string) interface{} {
+	for i := 0; i+1 < len(e.keyvals); i += 2 {
+		if e.keyvals[i] == key {
+			return e.keyvals[i+1]
+		}
+	}
+	return nil
+}
+
+func TestLoggerRedact(t *testing.T) {
+	var entries []testLogEntry
+	con := Config{
+		PfxPass: "pfx-password",
+		Logger: LoggerFunc(func(level LogLevel, msg string, keyvals ...interface{}) {
+			entries = append(entries, testLogEntry{level, msg, keyvals})
+		}),
+	}
+
+	con.logger("etc-password").Log(LogDebug, "test",
+		"url", "/jpnic/entryinfo.do;jsessionid=ABCDEF?org.apache.struts.taglib.html.TOKEN=0123456789abcdef&x=1",
+		"body", "PSWD=etc-password&pfx=pfx-password",
+		"html", `<input type="hidden" name="org.apache.struts.taglib.html.TOKEN" value="0123456789abcdef">`,
+		"contact", "担当者 user@example.ad.jp TEL 03-1234-5678 / +81-90-1234-5678 / 0312345678",
+		"password", 1234,
+		"ip", "192.168.0.1/24 2021-10-01",
+		"error", errLogTest,
+		"duration", 1500*time.Millisecond,
+	)
+
+	if len(entries) != 1 {
+		t.Fatalf("entries: %d", len(entries))
+	}
+	entry := entries[0]
+	for _, key := range []string{"url", "body", "html", "contact"} {
+		value := entry.value(key).(string)
+		for _, secret := range []string{"ABCDEF", "0123456789abcdef", "etc-password", "pfx-password", "user@example", "1234-5678", "0312345678"} {
+			if strings.Contains(value, secret) {
+				t.Errorf("%s: %s", key, value)
+			}
+		}
+	}
+	if entry.value("password") != redacted {
+		t.Errorf("password: %v", entry.value("password"))
+	}
+	if entry.value("ip") != "192.168.0.1/24 2021-10-01" {
+		t.Errorf("ip: %v", entry.value("ip"))
+	}
+	// 文字列以外の値は型を変えずに渡す
+	if entry.value("error") != errLogTest || entry.value("duration") != 1500*time.Millisecond {
+		t.Errorf("error: %#v duration: %#v", entry.value("error"), entry.value("duration"))
+	}
+}
+
+func TestLoggerHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	r := newTestRequest(t, srv.URL+"/jpnic/menu.do")
+	r.Client.Transport.(*retryTransport).logger = (&Config{Logger: NewLogger(log.New(&buf, "", 0), LogDebug)}).logger()
+	r.Client.Transport.(*retryTransport).menu = "IPv4"
+
+	resp, err := r.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	line := buf.String()
+	for _, want := range []string{"level=debug", `msg="http"`, "menu=IPv4", "method=GET", "path=/jpnic/menu.do", "status=200", "bytes=10", "duration="} {
+		if !strings.Contains(line, want) {
+			t.Errorf("%q not in %q", want, line)
+		}
+	}
+
+	// Loggerが設定されていない場合は何も出力しない
+	if _, ok := (&Config{}).logger().(nopLogger); !ok {
+		t.Fatal("expected nopLogger")
+	}
+}
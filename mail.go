@@ -0,0 +1,278 @@
+package jpnic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/transform"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MailNotification はJPNICから届く申請受付・完了等の通知メールの内容です。
+type MailNotification struct {
+	MessageID string    `json:"message_id"`
+	From      string    `json:"from"`
+	Subject   string    `json:"subject"`
+	Date      time.Time `json:"date"`
+	// 申請一覧と同じ形式の申請情報(メールに記載されていない項目は空になります)
+	Request RequestInfo `json:"request"`
+	// 申請の結果(受付・完了・却下等)
+	Outcome     RequestStatus `json:"outcome"`
+	IPAddress   []string      `json:"ip_address"`
+	NetworkName string        `json:"network_name"`
+	// 本文中に記載されているJPNICハンドル
+	Handles []string `json:"handles"`
+	// 本文中の「項目: 値」「[項目] 値」形式の行
+	Fields []RequestDetailField `json:"fields"`
+	Body   string               `json:"body"`
+}
+
+var (
+	mailBracketRegexp = regexp.MustCompile(`^\s*(?:[a-zA-Z0-9]{1,2}\.\s*)?[\[【]([^\]】]+)[\]】]\s*(.*)$`)
+	mailColonRegexp   = regexp.MustCompile(`^\s*(?:[a-zA-Z0-9]{1,2}\.\s*)?([^:：]{1,30}?)\s*[:：]\s*(.+)$`)
+	mailRecepNoRegexp = regexp.MustCompile(`受付番号[^0-9\n]{0,10}([0-9]+)`)
+	handleRegexp      = regexp.MustCompile(`\b[A-Z]{1,6}[0-9]+JP\b`)
+)
+
+// ParseMail はRFC 5322形式の通知メールを解析します。
+// ISO-2022-JP・Shift_JIS・EUC-JP・UTF-8の本文と、MIMEマルチパート・Base64・Quoted-Printableに対応しています。
+func ParseMail(r io.Reader) (MailNotification, error) {
+	var n MailNotification
+
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return n, err
+	}
+
+	n.MessageID = strings.Trim(msg.Header.Get("Message-Id"), "<> ")
+	n.From = decodeMailHeader(msg.Header.Get("From"))
+	n.Subject = decodeMailHeader(msg.Header.Get("Subject"))
+	if date, err := msg.Header.Date(); err == nil {
+		n.Date = date
+	}
+
+	body, err := readMailPart(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return n, err
+	}
+	n.Body = strings.ReplaceAll(body, "\r\n", "\n")
+
+	parseMailBody(&n)
+
+	return n, nil
+}
+
+func parseMailBody(n *MailNotification) {
+	for _, line := range strings.Split(n.Body, "\n") {
+		var title, value string
+		if match := mailBracketRegexp.FindStringSubmatch(line); match != nil {
+			title, value = match[1], match[2]
+		} else if match = mailColonRegexp.FindStringSubmatch(line); match != nil {
+			title, value = match[1], match[2]
+		} else {
+			continue
+		}
+		title = strings.TrimSpace(title)
+		value = strings.TrimSpace(value)
+		// URLの"https://"等は項目として扱わない
+		if title == "" || value == "" || strings.HasPrefix(value, "//") {
+			continue
+		}
+
+		n.Fields = append(n.Fields, RequestDetailField{Name: title, Value: value})
+
+		switch {
+		case strings.Contains(title, "受付番号"):
+			fillString(&n.Request.RecepNo, value)
+		case strings.Contains(title, "審議番号"):
+			fillString(&n.Request.DeliNo, value)
+		case strings.Contains(title, "申請種別"), strings.Contains(title, "申請の種類"):
+			fillString(&n.Request.ApplyKind, value)
+		case strings.Contains(title, "申請区分"):
+			fillString(&n.Request.ApplyClass, value)
+		case strings.Contains(title, "申請者"):
+			fillString(&n.Request.Applicant, value)
+		case strings.Contains(title, "申請日"):
+			fillString(&n.Request.ApplyDate, value)
+		case strings.Contains(title, "完了日"), strings.Contains(title, "処理日"):
+			fillString(&n.Request.CompleteDate, value)
+		case strings.Contains(title, "ステータス"), strings.Contains(title, "状態"), strings.Contains(title, "結果"):
+			fillString(&n.Request.Status, value)
+		case strings.Contains(title, "ネットワーク名"):
+			fillString(&n.NetworkName, value)
+		case strings.Contains(title, "アドレス") && !strings.Contains(title, "メール"):
+			for _, field := range strings.Fields(value) {
+				if isNetworkAddress(field) {
+					n.IPAddress = append(n.IPAddress, field)
+				}
+			}
+		}
+	}
+
+	if n.Request.RecepNo == "" {
+		if match := mailRecepNoRegexp.FindStringSubmatch(n.Body); match != nil {
+			n.Request.RecepNo = match[1]
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, handle := range handleRegexp.FindAllString(n.Body, -1) {
+		if !seen[handle] {
+			seen[handle] = true
+			n.Handles = append(n.Handles, handle)
+		}
+	}
+
+	// 本文にステータスが無い場合は件名から判定する
+	n.Outcome = ParseRequestStatus(n.Request.Status)
+	if n.Outcome == RequestStatusUnknown {
+		n.Outcome = ParseRequestStatus(n.Subject)
+	}
+	if n.Request.Status == "" && n.Outcome != RequestStatusUnknown {
+		n.Request.Status = n.Outcome.String()
+	}
+}
+
+func isNetworkAddress(str string) bool {
+	if _, _, err := net.ParseCIDR(str); err == nil {
+		return true
+	}
+	if strings.Contains(str, "-") {
+		for _, ip := range strings.SplitN(str, "-", 2) {
+			if net.ParseIP(strings.TrimSpace(ip)) == nil {
+				return false
+			}
+		}
+		return true
+	}
+	return net.ParseIP(str) != nil
+}
+
+// readMailPart はMIMEパートの本文をUTF-8で返します。マルチパートの場合はtext/plain、無ければtext/htmlのテキストを返します。
+func readMailPart(contentType, transferEncoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+		params = map[string]string{}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		var html string
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+
+			partType := part.Header.Get("Content-Type")
+			if partType == "" {
+				partType = "text/plain"
+			}
+			text, err := readMailPart(partType, part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", err
+			}
+
+			partMediaType, _, _ := mime.ParseMediaType(partType)
+			switch {
+			case partMediaType == "text/plain", strings.HasPrefix(partMediaType, "multipart/") && text != "":
+				return text, nil
+			case partMediaType == "text/html" && html == "":
+				html = text
+			}
+		}
+		return html, nil
+	}
+
+	if !strings.HasPrefix(mediaType, "text/") {
+		return "", nil
+	}
+
+	raw, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	text, err := decodeMailText(params["charset"], raw)
+	if err != nil {
+		return "", err
+	}
+
+	if mediaType == "text/html" {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+		if err != nil {
+			return "", err
+		}
+		return doc.Text(), nil
+	}
+	return text, nil
+}
+
+// decodeMailText はcharsetの指定が無い場合、ISO-2022-JP(エスケープシーケンスを含む)・UTF-8・Shift_JISの順に判定します。
+func decodeMailText(charset string, raw []byte) (string, error) {
+	if charset == "" {
+		switch {
+		case bytes.IndexByte(raw, 0x1b) >= 0:
+			charset = "iso-2022-jp"
+		case utf8.Valid(raw):
+			charset = "utf-8"
+		default:
+			charset = "shift_jis"
+		}
+	}
+
+	reader, err := mailCharsetReader(charset, bytes.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+func mailCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, ok := lookupCharset(charset)
+	if !ok {
+		return nil, fmt.Errorf("未対応の文字コードです: %s", charset)
+	}
+	return transform.NewReader(input, enc.NewDecoder()), nil
+}
+
+// decodeMailHeader はRFC 2047でエンコードされたヘッダと、エンコードされずにISO-2022-JPのまま入っているヘッダを復号します。
+func decodeMailHeader(header string) string {
+	if strings.IndexByte(header, 0x1b) >= 0 {
+		if text, err := decodeMailText("iso-2022-jp", []byte(header)); err == nil {
+			return text
+		}
+	}
+
+	decoder := mime.WordDecoder{CharsetReader: mailCharsetReader}
+	text, err := decoder.DecodeHeader(header)
+	if err != nil {
+		return header
+	}
+	return text
+}
@@ -0,0 +1,101 @@
+package jpnic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func encodeMailText(t *testing.T, str string, encoder transform.Transformer) []byte {
+	b, err := ioutil.ReadAll(transform.NewReader(strings.NewReader(str), encoder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseMailISO2022JP(t *testing.T) {
+	body := "資源管理者 各位\r\n" +
+		"\r\n" +
+		"以下の申請を受け付けました。\r\n" +
+		"\r\n" +
+		"受付番号：020210901000001\r\n" +
+		"申請種別：IPv4割り当て報告申請\r\n" +
+		"a. [IPネットワークアドレス]     192.0.2.0/24\r\n" +
+		"b. [ネットワーク名]             EXAMPLE-NET\r\n" +
+		"m. [管理者連絡窓口]             YY38053JP\r\n" +
+		"n. [技術連絡担当者]             YY36773JP\r\n" +
+		"\r\n" +
+		"https://www.nic.ad.jp/\r\n"
+
+	var buf bytes.Buffer
+	buf.WriteString("From: JPNIC <ip-service@nic.ad.jp>\r\n")
+	subject := base64.StdEncoding.EncodeToString(encodeMailText(t, "[JPNIC] 申請受付のお知らせ", japanese.ISO2022JP.NewEncoder()))
+	buf.WriteString("Subject: =?ISO-2022-JP?B?" + subject + "?=\r\n")
+	buf.WriteString("Date: Wed, 01 Sep 2021 10:00:00 +0900\r\n")
+	buf.WriteString("Message-ID: <abc@nic.ad.jp>\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=ISO-2022-JP\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 7bit\r\n\r\n")
+	buf.Write(encodeMailText(t, body, japanese.ISO2022JP.NewEncoder()))
+
+	n, err := ParseMail(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Subject != "[JPNIC] 申請受付のお知らせ" || n.MessageID != "abc@nic.ad.jp" || n.Date.IsZero() {
+		t.Errorf("header: %+v", n)
+	}
+	if n.Request.RecepNo != "020210901000001" || n.Request.ApplyKind != "IPv4割り当て報告申請" {
+		t.Errorf("request: %+v", n.Request)
+	}
+	if n.Outcome != RequestStatusReceived || n.Request.Status != "受付" {
+		t.Errorf("outcome: %s %q", n.Outcome, n.Request.Status)
+	}
+	if len(n.IPAddress) != 1 || n.IPAddress[0] != "192.0.2.0/24" || n.NetworkName != "EXAMPLE-NET" {
+		t.Errorf("network: %v %q", n.IPAddress, n.NetworkName)
+	}
+	if len(n.Handles) != 2 || n.Handles[0] != "YY38053JP" || n.Handles[1] != "YY36773JP" {
+		t.Errorf("handles: %v", n.Handles)
+	}
+}
+
+func TestParseMailMultipartShiftJIS(t *testing.T) {
+	body := "受付番号 : 020210901000002\r\n" +
+		"ステータス : 完了\r\n" +
+		"IPアドレス : 2001:db8::/48\r\n"
+	encoded := base64.StdEncoding.EncodeToString(encodeMailText(t, body, japanese.ShiftJIS.NewEncoder()))
+
+	raw := "From: ip-service@nic.ad.jp\r\n" +
+		"Subject: =?Shift_JIS?B?" + base64.StdEncoding.EncodeToString(encodeMailText(t, "申請完了", japanese.ShiftJIS.NewEncoder())) + "?=\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/alternative; boundary=\"BOUNDARY\"\r\n\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: text/plain; charset=Shift_JIS\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		encoded + "\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: text/html; charset=UTF-8\r\n\r\n" +
+		"<html><body>HTML</body></html>\r\n" +
+		"--BOUNDARY--\r\n"
+
+	n, err := ParseMail(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Subject != "申請完了" {
+		t.Errorf("subject: %q", n.Subject)
+	}
+	if n.Request.RecepNo != "020210901000002" || n.Outcome != RequestStatusCompleted {
+		t.Errorf("request: %+v %s", n.Request, n.Outcome)
+	}
+	if len(n.IPAddress) != 1 || n.IPAddress[0] != "2001:db8::/48" {
+		t.Errorf("ip address: %v", n.IPAddress)
+	}
+}
@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/homenoc/jpnic-go"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"strings"
+	"time"
+)
+
+// イベントの種類
+const (
+	EventRequestStatusChanged = "request.status_changed"
+	EventNetworkAdded         = "network.added"
+	EventNetworkReturned      = "network.returned"
+	EventHandleChanged        = "handle.changed"
+	EventUtilizationExceeded  = "resource.utilization_exceeded"
+	EventUtilizationRecovered = "resource.utilization_recovered"
+)
+
+// Event はWebhookで送信するJSONです。Dataの型はTypeによって異なります。
+type Event struct {
+	ID   string      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// RequestStatusChanged はEventRequestStatusChangedのDataです。
+type RequestStatusChanged struct {
+	RecepNo string            `json:"recep_no"`
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Request jpnic.RequestInfo `json:"request"`
+}
+
+// UtilizationCrossed はEventUtilizationExceeded/EventUtilizationRecoveredのDataです。
+// CIDRBlockが空の場合は資源管理者全体の利用率です。
+type UtilizationCrossed struct {
+	ResourceManager string  `json:"resource_manager"`
+	CIDRBlock       string  `json:"cidr_block,omitempty"`
+	Threshold       float64 `json:"threshold"`
+	Old             float64 `json:"old"`
+	New             float64 `json:"new"`
+}
+
+// Notifier はイベントを全てのWebhookに送信します。
+type Notifier struct {
+	Webhooks []*Webhook
+	// 利用率(%)の閾値。上回った場合にEventUtilizationExceeded、下回った場合にEventUtilizationRecoveredを送信します。
+	Thresholds []float64
+}
+
+// NewEvent はIDと時刻を設定したイベントを返します。
+func NewEvent(eventType string, data interface{}) Event {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return Event{
+		ID:   hex.EncodeToString(id),
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	}
+}
+
+// Notify はイベントを全てのWebhookに送信します。送信に失敗したWebhookがあった場合はまとめてエラーを返します。
+func (n *Notifier) Notify(ctx context.Context, events ...Event) error {
+	var errs []string
+	for _, event := range events {
+		for _, webhook := range n.Webhooks {
+			if err := webhook.Send(ctx, event); err != nil {
+				errs = append(errs, fmt.Sprintf("%s(%s): %s", event.Type, event.ID, err))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// OnTransition はjpnic.RequestWatcherのOnTransitionに設定する関数を返します。
+// 監視開始時の初回取得(FromがRequestStatusUnknown)は変化ではないため送信しません。
+func (n *Notifier) OnTransition(ctx context.Context, onError func(error)) func(jpnic.RequestTransition) {
+	return func(t jpnic.RequestTransition) {
+		if t.From == jpnic.RequestStatusUnknown {
+			return
+		}
+		err := n.Notify(ctx, TransitionEvent(t))
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// TransitionEvent は申請のステータスの変化をイベントにします。
+func TransitionEvent(t jpnic.RequestTransition) Event {
+	event := NewEvent(EventRequestStatusChanged, RequestStatusChanged{
+		RecepNo: t.RecepNo,
+		From:    t.From.String(),
+		To:      t.To.String(),
+		Request: t.Info,
+	})
+	event.Time = t.Time
+	return event
+}
+
+// ReportEvents は同期で検出したネットワークの追加・返却とJPNICハンドルの変更をイベントにします。
+func ReportEvents(r *snapshot.Report) []Event {
+	var events []Event
+	for _, detail := range r.NetworksAdded {
+		events = append(events, NewEvent(EventNetworkAdded, detail))
+	}
+	for _, detail := range r.NetworksReturned {
+		events = append(events, NewEvent(EventNetworkReturned, detail))
+	}
+	for _, change := range r.HandlesChanged {
+		events = append(events, NewEvent(EventHandleChanged, change))
+	}
+	return events
+}
+
+// UtilizationEvents は資源管理者全体とCIDRブロック毎の利用率が閾値を跨いだ場合にイベントを返します。
+// oldがnilの場合は前回の利用率を0として扱います。
+func (n *Notifier) UtilizationEvents(old *jpnic.ResourceInfo, new jpnic.ResourceInfo) []Event {
+	manager := new.ResourceManagerInfo.Ryakusyo
+
+	var oldRatio float64
+	oldBlocks := make(map[string]float64)
+	if old != nil {
+		oldRatio = old.UtilizationRatio
+		for _, block := range old.ResourceCIDRBlock {
+			oldBlocks[block.Address] = block.UtilizationRatio
+		}
+	}
+
+	events := n.crossed(UtilizationCrossed{ResourceManager: manager, Old: oldRatio, New: new.UtilizationRatio})
+	for _, block := range new.ResourceCIDRBlock {
+		events = append(events, n.crossed(UtilizationCrossed{
+			ResourceManager: manager,
+			CIDRBlock:       block.Address,
+			Old:             oldBlocks[block.Address],
+			New:             block.UtilizationRatio,
+		})...)
+	}
+	return events
+}
+
+func (n *Notifier) crossed(data UtilizationCrossed) []Event {
+	var events []Event
+	for _, threshold := range n.Thresholds {
+		data.Threshold = threshold
+		switch {
+		case data.Old < threshold && data.New >= threshold:
+			events = append(events, NewEvent(EventUtilizationExceeded, data))
+		case data.Old >= threshold && data.New < threshold:
+			events = append(events, NewEvent(EventUtilizationRecovered, data))
+		}
+	}
+	return events
+}
+
+// SnapshotEvents は前回と今回の同期結果から送信すべきイベントを返します。
+func (n *Notifier) SnapshotEvents(old, new *snapshot.Snapshot) []Event {
+	events := ReportEvents(snapshot.Diff(old, new))
+	if new.Resource != nil {
+		events = append(events, n.UtilizationEvents(old.Resource, *new.Resource)...)
+	}
+	return events
+}
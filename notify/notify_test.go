@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/homenoc/jpnic-go"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type receiver struct {
+	secret []byte
+	// 先頭からfailures回は500を返します。
+	failures int
+
+	mu     sync.Mutex
+	calls  int
+	events []Event
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls++
+	if r.calls <= r.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	if !Verify(r.secret, body, req.Header.Get(TimestampHeader), req.Header.Get(SignatureHeader)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil || event.Type != req.Header.Get(EventHeader) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.events = append(r.events, event)
+}
+
+func TestWebhookRetry(t *testing.T) {
+	recv := &receiver{secret: []byte("secret"), failures: 2}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL, Secret: recv.secret, RetryWait: time.Millisecond}
+	err := w.Send(context.Background(), TransitionEvent(jpnic.RequestTransition{
+		RecepNo: "1",
+		From:    jpnic.RequestStatusReceived,
+		To:      jpnic.RequestStatusCompleted,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recv.calls != 3 || len(recv.events) != 1 || recv.events[0].Type != EventRequestStatusChanged {
+		t.Fatalf("calls: %d, events: %+v", recv.calls, recv.events)
+	}
+	data := recv.events[0].Data.(map[string]interface{})
+	if data["recep_no"] != "1" || data["from"] != "受付" || data["to"] != "完了" {
+		t.Fatalf("data: %+v", data)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret, body := []byte("secret"), []byte(`{"type":"test"}`)
+	now := time.Unix(1760000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign(secret, timestamp, body)
+
+	for _, tt := range []struct {
+		name      string
+		timestamp string
+		signature string
+		now       time.Time
+		want      bool
+	}{
+		{"一致", timestamp, signature, now, true},
+		{"許容範囲内", timestamp, signature, now.Add(SignatureTolerance - time.Second), true},
+		{"古い送信時刻", timestamp, signature, now.Add(SignatureTolerance + time.Second), false},
+		{"未来の送信時刻", timestamp, signature, now.Add(-SignatureTolerance - time.Second), false},
+		{"送信時刻の改ざん", strconv.FormatInt(now.Unix()+1, 10), signature, now, false},
+		{"送信時刻なし", "", signature, now, false},
+		{"形式", timestamp, strings.TrimPrefix(signature, "sha256="), now, false},
+	} {
+		if got := verify(secret, body, tt.timestamp, tt.signature, tt.now); got != tt.want {
+			t.Errorf("%s: %v", tt.name, got)
+		}
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	// 署名が一致しないため401になり、再送せずにdead letterに記録されます。
+	recv := &receiver{secret: []byte("secret")}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	w := &Webhook{URL: srv.URL, Secret: []byte("wrong"), RetryWait: time.Millisecond, DeadLetterPath: path}
+
+	event := NewEvent(EventNetworkAdded, jpnic.InfoDetail{IPAddress: "192.0.2.0/24"})
+	if err := w.Send(context.Background(), event); err == nil {
+		t.Fatal("expected error")
+	}
+	if recv.calls != 1 {
+		t.Fatalf("calls: %d", recv.calls)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		t.Fatal("dead letter is empty")
+	}
+	var dl DeadLetter
+	if err = json.Unmarshal(scanner.Bytes(), &dl); err != nil {
+		t.Fatal(err)
+	}
+	if dl.Event.ID != event.ID || dl.URL != srv.URL || dl.Error == "" {
+		t.Fatalf("dead letter: %+v", dl)
+	}
+}
+
+func TestSnapshotEvents(t *testing.T) {
+	n := &Notifier{Thresholds: []float64{80, 90}}
+
+	old := &snapshot.Snapshot{
+		IPv4: []jpnic.InfoIPv4{{IPAddress: "192.0.2.0/26", NetworkName: "NET-A"}},
+		Resource: &jpnic.ResourceInfo{UtilizationRatio: 75, ResourceCIDRBlock: []jpnic.ResourceCIDRBlock{
+			{Address: "192.0.2.0/24", UtilizationRatio: 95},
+		}},
+	}
+	new := &snapshot.Snapshot{
+		IPv4:    []jpnic.InfoIPv4{{IPAddress: "192.0.2.64/26", NetworkName: "NET-B"}},
+		Handles: []jpnic.JPNICHandleDetail{{JPNICHandle: "YY38053JP"}},
+		Resource: &jpnic.ResourceInfo{UtilizationRatio: 92, ResourceCIDRBlock: []jpnic.ResourceCIDRBlock{
+			{Address: "192.0.2.0/24", UtilizationRatio: 85},
+		}},
+	}
+
+	var types []string
+	for _, event := range n.SnapshotEvents(old, new) {
+		types = append(types, event.Type)
+	}
+
+	want := []string{
+		EventNetworkAdded, EventNetworkReturned, EventHandleChanged,
+		EventUtilizationExceeded, EventUtilizationExceeded, EventUtilizationRecovered,
+	}
+	if len(types) != len(want) {
+		t.Fatalf("types: %v", types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("types: %v", types)
+		}
+	}
+}
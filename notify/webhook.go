@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader には"<TimestampHeaderの値>.<リクエストBody>"のHMAC-SHA256を"sha256=<hex>"の形式で設定します。
+	SignatureHeader = "X-JPNIC-Signature"
+	// TimestampHeader には送信時刻(UNIX時間の秒)を設定します。再送の度に更新します。
+	TimestampHeader = "X-JPNIC-Timestamp"
+	EventHeader     = "X-JPNIC-Event"
+	DeliveryHeader  = "X-JPNIC-Delivery"
+)
+
+// SignatureTolerance はVerifyで受け入れる送信時刻と現在時刻の差の上限です。(リプレイ攻撃の対策)
+const SignatureTolerance = 5 * time.Minute
+
+// Webhook はイベントをJSONでPOSTする送信先です。
+type Webhook struct {
+	URL string
+	// HMAC-SHA256の署名に使う共有鍵(空の場合は署名しません)
+	Secret []byte
+	// nilの場合はタイムアウト30秒のhttp.Clientを使います。
+	Client *http.Client
+	// 初回送信に失敗した後の再送回数(0の場合は3回、負の場合は再送しません)
+	MaxRetries int
+	// 再送までの待ち時間の初期値(0の場合は1秒)。再送の度に倍にします。
+	RetryWait time.Duration
+	// 再送しても送信できなかったイベントを1行1JSONで追記するファイル(空の場合は破棄します)
+	DeadLetterPath string
+
+	mu sync.Mutex
+}
+
+// DeadLetter は送信できなかったイベントの記録です。
+type DeadLetter struct {
+	URL      string    `json:"url"`
+	Event    Event     `json:"event"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Send はイベントを送信します。2xx以外の応答の内、5xx・429・通信エラーの場合は再送し、それでも失敗した場合はDeadLetterPathに記録します。
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	retries := w.MaxRetries
+	if retries == 0 {
+		retries = 3
+	} else if retries < 0 {
+		retries = 0
+	}
+	wait := w.RetryWait
+	if wait == 0 {
+		wait = time.Second
+	}
+
+	for i := 0; ; i++ {
+		var retry bool
+		retry, err = w.post(ctx, event, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || i >= retries {
+			return w.fail(event, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return w.fail(event, ctx.Err())
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// fail は送信できなかったイベントをDeadLetterPathに記録し、送信時のエラーを返します。
+func (w *Webhook) fail(event Event, sendErr error) error {
+	if err := w.deadLetter(event, sendErr); err != nil {
+		return fmt.Errorf("%s (dead letterへの記録にも失敗しました: %s)", sendErr, err)
+	}
+	return sendErr
+}
+
+func (w *Webhook) post(ctx context.Context, event Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	if len(w.Secret) != 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(w.Secret, timestamp, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook %s: %s", w.URL, resp.Status)
+}
+
+func (w *Webhook) deadLetter(event Event, sendErr error) error {
+	if w.DeadLetterPath == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(DeadLetter{
+		URL:      w.URL,
+		Event:    event,
+		Error:    sendErr.Error(),
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	file, err := os.OpenFile(w.DeadLetterPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Sign はtimestamp + "." + bodyのHMAC-SHA256を"sha256=<hex>"の形式で返します。
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify は受信側でTimestampHeader・SignatureHeaderの値を検証します。
+// 送信時刻が現在時刻からSignatureTolerance以上離れている場合は、署名が一致しても受け入れません。
+func Verify(secret, body []byte, timestamp, signature string) bool {
+	return verify(secret, body, timestamp, signature, time.Now())
+}
+
+func verify(secret, body []byte, timestamp, signature string, now time.Time) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if diff := now.Sub(time.Unix(sec, 0)); diff > SignatureTolerance || diff < -SignatureTolerance {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
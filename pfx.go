@@ -0,0 +1,276 @@
+package jpnic
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+)
+
+// PKCS#12(RFC 7292)のうち、OpenSSL 3の既定値であるPBES2(AES)で暗号化され、SHA-2のMACが付いたファイルを読み込みます。
+// RC2/3DES等の旧形式のアルゴリズムの場合はErrUnsupportedAlgorithmを返すため、golang.org/x/crypto/pkcs12で読み込みます。
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidSHA1                = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+// decodePKCS12 はPKCS#12ファイルに含まれる全ての証明書と秘密鍵を返します。
+func decodePKCS12(data []byte, password []byte) ([]*x509.Certificate, []crypto.PrivateKey, error) {
+	var pfx pfxPdu
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return nil, nil, fmt.Errorf("PKCS#12の形式が不正です: %w", err)
+	}
+	if pfx.Version != 3 {
+		return nil, nil, fmt.Errorf("PKCS#12のバージョンが不正です: %d", pfx.Version)
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, pfx.AuthSafe.ContentType)
+	}
+
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, err
+	}
+
+	if len(pfx.MacData.Mac.Digest) != 0 {
+		if err := verifyPKCS12MAC(pfx.MacData, authSafe, password); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var contents []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, nil, err
+	}
+
+	var certs []*x509.Certificate
+	var keys []crypto.PrivateKey
+	for _, content := range contents {
+		var safeContents []byte
+		switch {
+		case content.ContentType.Equal(oidDataContentType):
+			if _, err := asn1.Unmarshal(content.Content.Bytes, &safeContents); err != nil {
+				return nil, nil, err
+			}
+		case content.ContentType.Equal(oidEncryptedDataContentType):
+			var encrypted encryptedData
+			if _, err := asn1.Unmarshal(content.Content.Bytes, &encrypted); err != nil {
+				return nil, nil, err
+			}
+			info := encrypted.EncryptedContentInfo
+			var err error
+			safeContents, err = decryptPBES2(info.ContentEncryptionAlgorithm, info.EncryptedContent, password)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, content.ContentType)
+		}
+
+		var bags []safeBag
+		if _, err := asn1.Unmarshal(safeContents, &bags); err != nil {
+			return nil, nil, err
+		}
+
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, nil, err
+				}
+				if !cb.ID.Equal(oidCertTypeX509) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, err
+				}
+				certs = append(certs, cert)
+			case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				der, err := decryptPKCS8(bag.Value.Bytes, password)
+				if err != nil {
+					return nil, nil, err
+				}
+				key, err := x509.ParsePKCS8PrivateKey(der)
+				if err != nil {
+					return nil, nil, ErrIncorrectPassword
+				}
+				keys = append(keys, key)
+			case bag.ID.Equal(oidKeyBag):
+				key, err := x509.ParsePKCS8PrivateKey(bag.Value.Bytes)
+				if err != nil {
+					return nil, nil, err
+				}
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return certs, keys, nil
+}
+
+// verifyPKCS12MAC はRFC 7292 Appendix Bの鍵導出でMACを検証します。
+func verifyPKCS12MAC(mac macData, content []byte, password []byte) error {
+	var h func() hash.Hash
+	var blockSize int
+	algorithm := mac.Mac.Algorithm.Algorithm
+	switch {
+	case algorithm.Equal(oidSHA1):
+		h, blockSize = sha1.New, 64
+	case algorithm.Equal(oidSHA256):
+		h, blockSize = sha256.New, 64
+	case algorithm.Equal(oidSHA384):
+		h, blockSize = sha512.New384, 128
+	case algorithm.Equal(oidSHA512):
+		h, blockSize = sha512.New, 128
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	if err := checkIterations(mac.Iterations); err != nil {
+		return err
+	}
+
+	bmp := bmpString(password)
+	defer zero(bmp)
+	key := pkcs12KDF(h, blockSize, mac.MacSalt, bmp, mac.Iterations, 3, h().Size())
+	defer zero(key)
+	if !hmacEqual(h, key, content, mac.Mac.Digest) {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+func hmacEqual(h func() hash.Hash, key, data, mac []byte) bool {
+	m := hmac.New(h, key)
+	m.Write(data)
+	return hmac.Equal(m.Sum(nil), mac)
+}
+
+// pkcs12KDF はRFC 7292 Appendix B.2の鍵導出関数です。
+func pkcs12KDF(h func() hash.Hash, v int, salt, password []byte, iterations int, id byte, size int) []byte {
+	fill := func(data []byte) []byte {
+		if len(data) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(data)+v-1)/v))
+		for i := range out {
+			out[i] = data[i%len(data)]
+		}
+		return out
+	}
+
+	d := bytes.Repeat([]byte{id}, v)
+	i := append(fill(salt), fill(password)...)
+	defer zero(i)
+
+	var result []byte
+	for len(result) < size {
+		hh := h()
+		hh.Write(d)
+		hh.Write(i)
+		a := hh.Sum(nil)
+		for n := 1; n < iterations; n++ {
+			hh = h()
+			hh.Write(a)
+			a = hh.Sum(nil)
+		}
+		result = append(result, a...)
+
+		// I_j = (I_j + B + 1) mod 2^(8v)
+		b := fill(a)[:v]
+		for j := 0; j < len(i); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				carry += int(i[j+k]) + int(b[k])
+				i[j+k] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+	return result[:size]
+}
+
+// bmpString はUTF-8のパスワードをNULL終端のUTF-16BEに変換します。
+func bmpString(password []byte) []byte {
+	runes := bytes.Runes(password)
+	defer func() {
+		for i := range runes {
+			runes[i] = 0
+		}
+	}()
+
+	result := make([]byte, 0, len(runes)*2+2)
+	for _, r := range utf16.Encode(runes) {
+		result = append(result, byte(r>>8), byte(r))
+	}
+	return append(result, 0, 0)
+}
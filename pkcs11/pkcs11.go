@@ -0,0 +1,447 @@
+package pkcs11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"github.com/homenoc/jpnic-go"
+	"github.com/miekg/pkcs11"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// Config はPKCS#11トークン上の秘密鍵の指定です。
+type Config struct {
+	// PKCS#11モジュールのパス (例: /usr/lib/softhsm/libsofthsm2.so)
+	Module string
+	// トークンのラベル(空の場合はSlotで指定)
+	TokenLabel string
+	Slot       uint
+	// ユーザPIN(PINSourceが設定されている場合はPINSourceから取得します)
+	PIN       string
+	PINSource jpnic.SecretSource
+	// 秘密鍵のラベル・ID(CKA_LABEL/CKA_ID)。両方指定した場合は両方が一致する鍵を使います。
+	KeyLabel string
+	KeyID    []byte
+}
+
+// Key はPKCS#11トークン上の秘密鍵で、crypto.Signerを実装します。
+// jpnic.ConfigのSignerに設定すると、トークン上の秘密鍵でTLSのクライアント認証を行います。
+// 署名はセッション毎に直列化されます。利用後はCloseしてください。
+type Key struct {
+	ctx     *pkcs11.Ctx
+	module  string
+	ref     bool // モジュールの参照を保持している場合はtrue(Close時に参照を削除します)
+	session pkcs11.SessionHandle
+	handle  pkcs11.ObjectHandle
+	public  crypto.PublicKey
+	mu      sync.Mutex
+}
+
+// moduleRefs はこのパッケージで初期化したPKCS#11モジュール毎の参照数です。
+// モジュールはプロセス内で共有されるため、最後に参照を削除したKeyのみがFinalizeします。
+var (
+	moduleMu   sync.Mutex
+	moduleRefs = make(map[string]int)
+)
+
+// Open はPKCS#11モジュールを読み込み、トークンにログインして秘密鍵を取得します。
+func Open(config Config) (*Key, error) {
+	if config.KeyLabel == "" && len(config.KeyID) == 0 {
+		return nil, fmt.Errorf("秘密鍵のラベルまたはIDが指定されていません")
+	}
+
+	ctx := pkcs11.New(config.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("PKCS#11モジュール %s を読み込めませんでした", config.Module)
+	}
+	ref, err := acquireModule(config.Module, ctx.Initialize)
+	if err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+
+	key := &Key{ctx: ctx, module: config.Module, ref: ref}
+	if err := key.open(config); err != nil {
+		key.Close()
+		return nil, err
+	}
+	return key, nil
+}
+
+func (k *Key) open(config Config) error {
+	slot, err := findSlot(k.ctx, config)
+	if err != nil {
+		return err
+	}
+
+	k.session, err = k.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return err
+	}
+
+	pin := config.PIN
+	if config.PINSource != nil {
+		secret, err := config.PINSource.Secret()
+		if err != nil {
+			return err
+		}
+		// PKCS#11のライブラリがstringでしか受け取れないため、PINはメモリ上から消去できません
+		pin = string(secret)
+		for i := range secret {
+			secret[i] = 0
+		}
+	}
+	if err = k.ctx.Login(k.session, pkcs11.CKU_USER, pin); err != nil && !isError(err, pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		return fmt.Errorf("トークンにログインできませんでした: %w", err)
+	}
+
+	template := []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY)}
+	if config.KeyLabel != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, config.KeyLabel))
+	}
+	if len(config.KeyID) != 0 {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, config.KeyID))
+	}
+	objects, err := k.findObjects(template, 2)
+	if err != nil {
+		return err
+	}
+	switch len(objects) {
+	case 0:
+		return fmt.Errorf("トークンに秘密鍵が見つかりません")
+	case 1:
+	default:
+		return fmt.Errorf("条件に一致する秘密鍵が複数あります。KeyLabelとKeyIDを指定してください")
+	}
+	k.handle = objects[0]
+
+	k.public, err = k.publicKey()
+	return err
+}
+
+// findSlot はTokenLabelが一致するトークンのスロットを返します。TokenLabelが空の場合はSlotを返します。
+func findSlot(ctx *pkcs11.Ctx, config Config) (uint, error) {
+	if config.TokenLabel == "" {
+		return config.Slot, nil
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(info.Label) == config.TokenLabel {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("トークン %s が見つかりません", config.TokenLabel)
+}
+
+func (k *Key) findObjects(template []*pkcs11.Attribute, max int) ([]pkcs11.ObjectHandle, error) {
+	if err := k.ctx.FindObjectsInit(k.session, template); err != nil {
+		return nil, err
+	}
+	defer k.ctx.FindObjectsFinal(k.session)
+
+	objects, _, err := k.ctx.FindObjects(k.session, max)
+	return objects, err
+}
+
+// publicKey は秘密鍵と対になる公開鍵を返します。
+// RSAは秘密鍵の属性から、ECは同じCKA_IDの公開鍵オブジェクト(無ければ証明書)から取得します。
+func (k *Key) publicKey() (crypto.PublicKey, error) {
+	attributes, err := k.ctx.GetAttributeValue(k.session, k.handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	keyType, id := attributes[0].Value, attributes[1].Value
+
+	switch {
+	case isKeyType(keyType, pkcs11.CKK_RSA):
+		attributes, err = k.ctx.GetAttributeValue(k.session, k.handle, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attributes[0].Value),
+			E: int(new(big.Int).SetBytes(attributes[1].Value).Int64()),
+		}, nil
+	case isKeyType(keyType, pkcs11.CKK_EC):
+		objects, err := k.findObjects([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		}, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(objects) != 0 {
+			attributes, err = k.ctx.GetAttributeValue(k.session, objects[0], []*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+				pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+			})
+			if err != nil {
+				return nil, err
+			}
+			return parseECPublicKey(attributes[0].Value, attributes[1].Value)
+		}
+
+		certs, err := k.certificates([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_ID, id)})
+		if err != nil {
+			return nil, err
+		}
+		if len(certs) == 0 {
+			return nil, fmt.Errorf("秘密鍵と対になる公開鍵がトークンに見つかりません")
+		}
+		return certs[0].PublicKey, nil
+	}
+	return nil, fmt.Errorf("対応していない鍵の種類です (CKA_KEY_TYPE=%x)", keyType)
+}
+
+var (
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidNamedCurveP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// parseECPublicKey はCKA_EC_PARAMS(曲線のOID)とCKA_EC_POINT(DERのOCTET STRING)から公開鍵を組み立てます。
+func parseECPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, fmt.Errorf("CKA_EC_PARAMSを読み込めませんでした: %w", err)
+	}
+
+	var curve elliptic.Curve
+	switch {
+	case oid.Equal(oidNamedCurveP256):
+		curve = elliptic.P256()
+	case oid.Equal(oidNamedCurveP384):
+		curve = elliptic.P384()
+	case oid.Equal(oidNamedCurveP521):
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("対応していない曲線です (%s)", oid)
+	}
+
+	// OCTET STRINGで包まれていないモジュールもあるため、その場合はそのまま使う
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) != 0 {
+		raw = point
+	}
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, fmt.Errorf("CKA_EC_POINTを読み込めませんでした")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// Public は秘密鍵と対になる公開鍵を返します。
+func (k *Key) Public() crypto.PublicKey {
+	return k.public
+}
+
+// Sign はトークン上の秘密鍵でdigestに署名します。RSA(PKCS#1 v1.5・PSS)とECDSAに対応しています。
+func (k *Key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var mechanism *pkcs11.Mechanism
+	data := digest
+
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			params, err := pssParams(pss, public)
+			if err != nil {
+				return nil, err
+			}
+			mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, params)
+			break
+		}
+
+		prefix, ok := digestInfoPrefix[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("対応していないハッシュ関数です (%s)", opts.HashFunc())
+		}
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+		data = append(append([]byte(nil), prefix...), digest...)
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+	default:
+		return nil, fmt.Errorf("対応していない鍵の種類です")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.ctx.SignInit(k.session, []*pkcs11.Mechanism{mechanism}, k.handle); err != nil {
+		return nil, err
+	}
+	signature, err := k.ctx.Sign(k.session, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := k.public.(*ecdsa.PublicKey); ok {
+		// PKCS#11のECDSA署名はr||sのため、crypto.Signerが返すASN.1形式に変換する
+		half := len(signature) / 2
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			R: new(big.Int).SetBytes(signature[:half]),
+			S: new(big.Int).SetBytes(signature[half:]),
+		})
+	}
+	return signature, nil
+}
+
+// Certificates はトークンに保存されている全てのX.509証明書を返します。
+// jpnic.ConfigにCertFilePath/CertDataが無い場合、この中から秘密鍵と対になる証明書と中間証明書が選ばれます。
+func (k *Key) Certificates() ([]*x509.Certificate, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.certificates(nil)
+}
+
+func (k *Key) certificates(template []*pkcs11.Attribute) ([]*x509.Certificate, error) {
+	template = append([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_CERTIFICATE_TYPE, pkcs11.CKC_X_509),
+	}, template...)
+	objects, err := k.findObjects(template, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for _, object := range objects {
+		attributes, err := k.ctx.GetAttributeValue(k.session, object, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(attributes[0].Value)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// Close はトークンからログアウトし、PKCS#11モジュールを解放します。
+// 同じモジュールを使う他のKeyが残っている場合や、このパッケージ以外で初期化されていた場合はFinalizeしません。
+func (k *Key) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.ctx == nil {
+		return nil
+	}
+	if k.session != 0 {
+		k.ctx.Logout(k.session)
+		k.ctx.CloseSession(k.session)
+	}
+	var err error
+	if k.ref {
+		err = releaseModule(k.module, k.ctx.Finalize)
+		k.ref = false
+	}
+	k.ctx.Destroy()
+	k.ctx = nil
+	return err
+}
+
+// acquireModule はinitializeでモジュールを初期化し、参照を追加した場合はtrueを返します。
+// 初期化済みでこのパッケージの参照が無い場合(他のライブラリが初期化した場合)は参照を追加しません。
+func acquireModule(module string, initialize func() error) (bool, error) {
+	moduleMu.Lock()
+	defer moduleMu.Unlock()
+
+	if err := initialize(); err != nil {
+		if !isError(err, pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+			return false, err
+		}
+		if moduleRefs[module] == 0 {
+			return false, nil
+		}
+	}
+	moduleRefs[module]++
+	return true, nil
+}
+
+// releaseModule はモジュールの参照を削除し、最後の参照だった場合はfinalizeを呼びます。
+func releaseModule(module string, finalize func() error) error {
+	moduleMu.Lock()
+	defer moduleMu.Unlock()
+
+	moduleRefs[module]--
+	if moduleRefs[module] > 0 {
+		return nil
+	}
+	delete(moduleRefs, module)
+	return finalize()
+}
+
+// digestInfoPrefix はPKCS#1 v1.5署名でハッシュ値の前に付けるDigestInfoです。(crypto/rsaと同じ値)
+var digestInfoPrefix = map[crypto.Hash][]byte{
+	crypto.MD5SHA1: {},
+	crypto.SHA1:    {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA224:  {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA256:  {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384:  {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512:  {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// pssParams はCKM_RSA_PKCS_PSSのパラメータを返します。
+func pssParams(opts *rsa.PSSOptions, public *rsa.PublicKey) ([]byte, error) {
+	var hashAlg, mgf uint
+	switch opts.Hash {
+	case crypto.SHA1:
+		hashAlg, mgf = pkcs11.CKM_SHA_1, pkcs11.CKG_MGF1_SHA1
+	case crypto.SHA224:
+		hashAlg, mgf = pkcs11.CKM_SHA224, pkcs11.CKG_MGF1_SHA224
+	case crypto.SHA256:
+		hashAlg, mgf = pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256
+	case crypto.SHA384:
+		hashAlg, mgf = pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384
+	case crypto.SHA512:
+		hashAlg, mgf = pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512
+	default:
+		return nil, fmt.Errorf("対応していないハッシュ関数です (%s)", opts.Hash)
+	}
+
+	saltLength := opts.SaltLength
+	switch saltLength {
+	case rsa.PSSSaltLengthEqualsHash:
+		saltLength = opts.Hash.Size()
+	case rsa.PSSSaltLengthAuto:
+		saltLength = (public.N.BitLen()-1+7)/8 - 2 - opts.Hash.Size()
+	}
+	return pkcs11.NewPSSParams(hashAlg, mgf, uint(saltLength)), nil
+}
+
+func isError(err error, code uint) bool {
+	var p11Err pkcs11.Error
+	return errors.As(err, &p11Err) && uint(p11Err) == code
+}
+
+// isKeyType はCKA_KEY_TYPEの値(ネイティブのバイトオーダーのCK_ULONG)がkeyTypeと一致するかを返します。
+func isKeyType(value []byte, keyType uint) bool {
+	return bytes.Equal(value, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType).Value)
+}
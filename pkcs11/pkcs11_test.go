@@ -0,0 +1,212 @@
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"github.com/homenoc/jpnic-go"
+	"github.com/miekg/pkcs11"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseECPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, _ := asn1.Marshal(oidNamedCurveP256)
+	raw := elliptic.Marshal(elliptic.P256(), key.X, key.Y)
+	wrapped, _ := asn1.Marshal(raw)
+
+	for _, point := range [][]byte{wrapped, raw} {
+		public, err := parseECPublicKey(params, point)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !public.Equal(&key.PublicKey) {
+			t.Fatal("public key mismatch")
+		}
+	}
+
+	params, _ = asn1.Marshal(asn1.ObjectIdentifier{1, 2, 3})
+	if _, err = parseECPublicKey(params, wrapped); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestPSSParams(t *testing.T) {
+	public := &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), 2047), E: 65537}
+
+	if _, err := pssParams(&rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}, public); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pssParams(&rsa.PSSOptions{Hash: crypto.MD5}, public); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestSoftHSM はSoftHSM等のトークンに作成した秘密鍵で署名・TLSのクライアント認証を行います。
+// 環境変数 JPNIC_PKCS11_MODULE, JPNIC_PKCS11_TOKEN, JPNIC_PKCS11_PIN, JPNIC_PKCS11_KEY_LABEL が無い場合はスキップします。
+//
+//	softhsm2-util --init-token --free --label jpnic --pin 1234 --so-pin 123456
+//	pkcs11-tool --module /usr/lib/softhsm/libsofthsm2.so --token-label jpnic --login --pin 1234 \
+//	    --keypairgen --key-type rsa:2048 --label client --id 01
+func TestModuleRefs(t *testing.T) {
+	const module = "/usr/lib/test/libpkcs11.so"
+	initialized := false
+	initialize := func() error {
+		if initialized {
+			return pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)
+		}
+		initialized = true
+		return nil
+	}
+	finalized := 0
+	finalize := func() error {
+		finalized++
+		initialized = false
+		return nil
+	}
+
+	// 2つ目のKeyは1つ目のKeyが残っている間はFinalizeしない
+	first, err := acquireModule(module, initialize)
+	if err != nil || !first {
+		t.Fatal(first, err)
+	}
+	second, err := acquireModule(module, initialize)
+	if err != nil || !second {
+		t.Fatal(second, err)
+	}
+	if err = releaseModule(module, finalize); err != nil || finalized != 0 {
+		t.Fatalf("finalized: %d %v", finalized, err)
+	}
+	if err = releaseModule(module, finalize); err != nil || finalized != 1 {
+		t.Fatalf("finalized: %d %v", finalized, err)
+	}
+
+	// 他のライブラリが初期化したモジュールは参照を持たない
+	initialized = true
+	if ref, err := acquireModule(module, initialize); err != nil || ref {
+		t.Fatal(ref, err)
+	}
+
+	if _, err = acquireModule(module, func() error { return pkcs11.Error(pkcs11.CKR_GENERAL_ERROR) }); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSoftHSM(t *testing.T) {
+	module := os.Getenv("JPNIC_PKCS11_MODULE")
+	if module == "" {
+		t.Skip("JPNIC_PKCS11_MODULE is not set")
+	}
+
+	key, err := Open(Config{
+		Module:     module,
+		TokenLabel: os.Getenv("JPNIC_PKCS11_TOKEN"),
+		PINSource:  jpnic.EnvSecret("JPNIC_PKCS11_PIN"),
+		KeyLabel:   os.Getenv("JPNIC_PKCS11_KEY_LABEL"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+
+	// トークンの鍵で自己署名証明書を作成する(署名を検証できればSignが正しい)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "jpnic-go pkcs11 test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cert.CheckSignatureFrom(cert); err != nil {
+		t.Fatal(err)
+	}
+
+	con := jpnic.Config{
+		Signer:   key,
+		CertData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+	report, err := con.CheckCredentials(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.KeyMatch {
+		t.Fatalf("%+v", report)
+	}
+
+	// TLS 1.2(PKCS#1 v1.5/ECDSA)と1.3(PSS/ECDSA)でクライアント認証を行う
+	for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+		if err = handshake(cert, key, version); err != nil {
+			t.Fatalf("TLS %x: %s", version, err)
+		}
+	}
+}
+
+func handshake(cert *x509.Certificate, key crypto.Signer, version uint16) error {
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, template, template, &serverKey.PublicKey, serverKey)
+	if err != nil {
+		return err
+	}
+
+	clientPool := x509.NewCertPool()
+	clientPool.AddCert(cert)
+	serverConn, clientConn := net.Pipe()
+	server := tls.Server(serverConn, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientPool,
+		MinVersion:   version,
+		MaxVersion:   version,
+	})
+	client := tls.Client(clientConn, &tls.Config{
+		Certificates:       []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}},
+		InsecureSkipVerify: true,
+		MinVersion:         version,
+		MaxVersion:         version,
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Handshake()
+		server.Close()
+	}()
+	err = client.Handshake()
+	client.Close()
+	if serverErr := <-errs; serverErr != nil {
+		return serverErr
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package rdap
+
+import (
+	"encoding/json"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Handler は同期済みのSnapshotを元に/ip/{prefix}と/entity/{handle}へ応答します。
+type Handler struct {
+	// 応答に含めるリンクのベースURL(例: "https://rdap.example.jp")。空の場合はリンクを含めません。
+	BaseURL string
+
+	mu    sync.RWMutex
+	index *snapshot.Index
+}
+
+// SetSnapshot は応答に使うデータを差し替えます。
+func (h *Handler) SetSnapshot(snap *snapshot.Snapshot) {
+	index := snapshot.NewIndex(snap)
+
+	h.mu.Lock()
+	h.index = index
+	h.mu.Unlock()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	h.mu.RLock()
+	index := h.index
+	h.mu.RUnlock()
+
+	if index == nil {
+		writeError(w, http.StatusServiceUnavailable, "Data Not Loaded")
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	switch {
+	case strings.HasPrefix(path, "/ip/"):
+		query, err := url.PathUnescape(strings.TrimPrefix(path, "/ip/"))
+		if err != nil || !snapshot.IsAddress(query) {
+			writeError(w, http.StatusBadRequest, "Bad Request")
+			return
+		}
+		detail, ok := index.Network(query)
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+
+		n := FromDetail(detail, index.Handle)
+		n.RDAPConformance = []string{conformance}
+		if h.BaseURL != "" {
+			self := detail.IPAddress
+			if !strings.Contains(self, "/") {
+				self = n.StartAddress
+			}
+			n.Links = []Link{h.link("ip/" + self)}
+			for i := range n.Entities {
+				n.Entities[i].Links = []Link{h.link("entity/" + url.PathEscape(n.Entities[i].Handle))}
+			}
+		}
+		writeJSON(w, http.StatusOK, n)
+	case strings.HasPrefix(path, "/entity/"):
+		handle, err := url.PathUnescape(strings.TrimPrefix(path, "/entity/"))
+		if err != nil || handle == "" {
+			writeError(w, http.StatusBadRequest, "Bad Request")
+			return
+		}
+		detail, ok := index.Handle(handle)
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+
+		entity := FromHandle(detail)
+		entity.RDAPConformance = []string{conformance}
+		if h.BaseURL != "" {
+			entity.Links = []Link{h.link("entity/" + url.PathEscape(detail.JPNICHandle))}
+		}
+		writeJSON(w, http.StatusOK, entity)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (h *Handler) link(path string) Link {
+	href := strings.TrimSuffix(h.BaseURL, "/") + "/" + path
+	return Link{
+		Value: href,
+		Rel:   "self",
+		Href:  href,
+		Type:  "application/rdap+json",
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, title string) {
+	writeJSON(w, status, Error{
+		RDAPConformance: []string{conformance},
+		ErrorCode:       status,
+		Title:           title,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/rdap+json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
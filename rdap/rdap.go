@@ -0,0 +1,260 @@
+package rdap
+
+import (
+	"github.com/homenoc/jpnic-go"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const conformance = "rdap_level_0"
+
+// IPNetwork はRFC 9083のIP Network Object Classです。
+type IPNetwork struct {
+	ObjectClassName string   `json:"objectClassName"`
+	RDAPConformance []string `json:"rdapConformance,omitempty"`
+	Handle          string   `json:"handle"`
+	StartAddress    string   `json:"startAddress,omitempty"`
+	EndAddress      string   `json:"endAddress,omitempty"`
+	IPVersion       string   `json:"ipVersion,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	Type            string   `json:"type,omitempty"`
+	Country         string   `json:"country,omitempty"`
+	Status          []string `json:"status,omitempty"`
+	Entities        []Entity `json:"entities,omitempty"`
+	Remarks         []Remark `json:"remarks,omitempty"`
+	Links           []Link   `json:"links,omitempty"`
+	Events          []Event  `json:"events,omitempty"`
+}
+
+// Entity はRFC 9083のEntity Object Classです。
+type Entity struct {
+	ObjectClassName string        `json:"objectClassName"`
+	RDAPConformance []string      `json:"rdapConformance,omitempty"`
+	Handle          string        `json:"handle"`
+	VCardArray      []interface{} `json:"vcardArray,omitempty"`
+	Roles           []string      `json:"roles,omitempty"`
+	Links           []Link        `json:"links,omitempty"`
+	Events          []Event       `json:"events,omitempty"`
+}
+
+type Event struct {
+	EventAction string `json:"eventAction"`
+	EventDate   string `json:"eventDate"`
+}
+
+type Remark struct {
+	Title       string   `json:"title,omitempty"`
+	Description []string `json:"description"`
+}
+
+type Link struct {
+	Value string `json:"value"`
+	Rel   string `json:"rel"`
+	Href  string `json:"href"`
+	Type  string `json:"type,omitempty"`
+}
+
+// Error はRFC 9083のError Response Bodyです。
+type Error struct {
+	RDAPConformance []string `json:"rdapConformance,omitempty"`
+	ErrorCode       int      `json:"errorCode"`
+	Title           string   `json:"title,omitempty"`
+	Description     []string `json:"description,omitempty"`
+}
+
+// Lookup はJPNICハンドルからEntityの元になる情報を取得する関数です。
+type Lookup func(handle string) (jpnic.JPNICHandleDetail, bool)
+
+func FromIPv4(info jpnic.InfoIPv4, lookup Lookup) IPNetwork {
+	return FromDetail(snapshot.IPv4Detail(info), lookup)
+}
+
+func FromIPv6(info jpnic.InfoIPv6, lookup Lookup) IPNetwork {
+	return FromDetail(snapshot.IPv6Detail(info), lookup)
+}
+
+// FromDetail はInfoDetailをIP Networkに変換します。管理者連絡窓口・技術連絡担当者はlookupで解決できた場合にEntityとして含めます。
+func FromDetail(detail jpnic.InfoDetail, lookup Lookup) IPNetwork {
+	n := IPNetwork{
+		ObjectClassName: "ip network",
+		Handle:          detail.IPAddress,
+		Name:            detail.NetworkName,
+		Type:            detail.Type,
+		Country:         "JP",
+		Status:          []string{"active"},
+	}
+
+	if start, end, ok := snapshot.ParseNetwork(detail.IPAddress); ok {
+		n.StartAddress = start.String()
+		n.EndAddress = end.String()
+		n.IPVersion = "v6"
+		if start.To4() != nil {
+			n.IPVersion = "v4"
+		}
+	}
+
+	if detail.ReturnDate != "" {
+		n.Status = []string{"inactive"}
+	}
+
+	if detail.InfraUserKind != "" {
+		n.Remarks = append(n.Remarks, Remark{Title: "infra user kind", Description: []string{detail.InfraUserKind}})
+	}
+	if detail.Org != "" || detail.OrgEn != "" {
+		var description []string
+		for _, org := range []string{detail.OrgEn, detail.Org} {
+			if org != "" {
+				description = append(description, org)
+			}
+		}
+		n.Remarks = append(n.Remarks, Remark{Title: "organization", Description: description})
+	}
+
+	n.Events = events(map[string]string{
+		"registration": detail.AssignDate,
+		"deletion":     detail.ReturnDate,
+		"last changed": detail.UpdateDate,
+	})
+
+	// 管理者連絡窓口と技術連絡担当者が同一の場合は1つのEntityにまとめる
+	roles := map[string][]string{}
+	var handles []string
+	for _, contact := range []struct {
+		handle string
+		role   string
+	}{
+		{detail.AdminJPNICHandle, "administrative"},
+		{detail.TechJPNICHandle, "technical"},
+	} {
+		if contact.handle == "" {
+			continue
+		}
+		if _, ok := roles[contact.handle]; !ok {
+			handles = append(handles, contact.handle)
+		}
+		roles[contact.handle] = append(roles[contact.handle], contact.role)
+	}
+
+	for _, handle := range handles {
+		entity := Entity{ObjectClassName: "entity", Handle: handle}
+		if lookup != nil {
+			if handleDetail, ok := lookup(handle); ok {
+				entity = FromHandle(handleDetail)
+			}
+		}
+		entity.Roles = roles[handle]
+		n.Entities = append(n.Entities, entity)
+	}
+
+	return n
+}
+
+// FromHandle はJPNICハンドル・グループハンドルをjCardを含むEntityに変換します。
+func FromHandle(detail jpnic.JPNICHandleDetail) Entity {
+	kind := "group"
+	if detail.IsJPNICHandle {
+		kind = "individual"
+	}
+
+	vcard := [][]interface{}{
+		{"version", map[string]string{}, "text", "4.0"},
+		{"kind", map[string]string{}, "text", kind},
+	}
+
+	name := detail.NameEn
+	if name == "" {
+		name = detail.Name
+	}
+	vcard = append(vcard, []interface{}{"fn", map[string]string{}, "text", name})
+	if detail.NameEn != "" && detail.Name != "" {
+		vcard = append(vcard, []interface{}{"fn", map[string]string{"language": "ja"}, "text", detail.Name})
+	}
+
+	if org := join(detail.OrgEn, detail.DivisionEn); org != nil {
+		vcard = append(vcard, []interface{}{"org", map[string]string{}, "text", org})
+	}
+	if org := join(detail.Org, detail.Division); org != nil && detail.Org != detail.OrgEn {
+		vcard = append(vcard, []interface{}{"org", map[string]string{"language": "ja"}, "text", org})
+	}
+	if detail.TitleEn != "" {
+		vcard = append(vcard, []interface{}{"title", map[string]string{}, "text", detail.TitleEn})
+	}
+	if detail.Title != "" && detail.Title != detail.TitleEn {
+		vcard = append(vcard, []interface{}{"title", map[string]string{"language": "ja"}, "text", detail.Title})
+	}
+	if detail.Email != "" {
+		vcard = append(vcard, []interface{}{"email", map[string]string{}, "text", detail.Email})
+	}
+	if detail.Tel != "" {
+		vcard = append(vcard, []interface{}{"tel", map[string]string{"type": "voice"}, "text", detail.Tel})
+	}
+	if detail.Fax != "" {
+		vcard = append(vcard, []interface{}{"tel", map[string]string{"type": "fax"}, "text", detail.Fax})
+	}
+
+	var properties []interface{}
+	for _, property := range vcard {
+		properties = append(properties, property)
+	}
+
+	return Entity{
+		ObjectClassName: "entity",
+		Handle:          detail.JPNICHandle,
+		VCardArray:      []interface{}{"vcard", properties},
+		Events:          events(map[string]string{"last changed": detail.UpdateDate}),
+	}
+}
+
+var dateRegexp = regexp.MustCompile(`^(\d{4})/(\d{1,2})/(\d{1,2})(?:\s+(\d{1,2}):(\d{2})(?::(\d{2}))?)?`)
+
+var jst = time.FixedZone("JST", 9*60*60)
+
+// parseDate はJPNICの"2020/02/14"、"2020/02/14 17:25:06(JST)"形式をRFC 3339に変換します。
+func parseDate(str string) (string, bool) {
+	match := dateRegexp.FindStringSubmatch(strings.TrimSpace(str))
+	if match == nil {
+		return "", false
+	}
+
+	layout := "2006/1/2"
+	value := match[1] + "/" + match[2] + "/" + match[3]
+	if match[4] != "" {
+		layout += " 15:04"
+		value += " " + match[4] + ":" + match[5]
+		if match[6] != "" {
+			layout += ":05"
+			value += ":" + match[6]
+		}
+	}
+
+	t, err := time.ParseInLocation(layout, value, jst)
+	if err != nil {
+		return "", false
+	}
+	return t.Format(time.RFC3339), true
+}
+
+func events(dates map[string]string) []Event {
+	var result []Event
+	for _, action := range []string{"registration", "deletion", "last changed"} {
+		if date, ok := parseDate(dates[action]); ok {
+			result = append(result, Event{EventAction: action, EventDate: date})
+		}
+	}
+	return result
+}
+
+func join(strs ...string) []string {
+	var result []string
+	for _, str := range strs {
+		if str != "" {
+			result = append(result, str)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package rdap
+
+import (
+	"encoding/json"
+	"github.com/homenoc/jpnic-go"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testHandler() *Handler {
+	h := &Handler{BaseURL: "https://rdap.example.jp/"}
+	h.SetSnapshot(&snapshot.Snapshot{
+		IPv4: []jpnic.InfoIPv4{{
+			IPAddress: "192.0.2.0/24",
+			InfoDetail: jpnic.InfoDetail{
+				IPAddress:        "192.0.2.0/24",
+				NetworkName:      "EXAMPLE-NET",
+				AdminJPNICHandle: "YY38053JP",
+				TechJPNICHandle:  "YY38053JP",
+				AssignDate:       "2020/02/14",
+				UpdateDate:       "2020/02/14 17:25:06(JST)",
+			},
+		}},
+		Handles: []jpnic.JPNICHandleDetail{{
+			IsJPNICHandle: true,
+			JPNICHandle:   "YY38053JP",
+			Name:          "山田 太郎",
+			NameEn:        "Yamada, Taro",
+			Email:         "taro@example.jp",
+		}},
+	})
+	return h
+}
+
+func TestIPNetwork(t *testing.T) {
+	rec := httptest.NewRecorder()
+	testHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ip/192.0.2.10", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/rdap+json" {
+		t.Fatalf("content type: %s", ct)
+	}
+
+	var n IPNetwork
+	if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n.ObjectClassName != "ip network" || n.IPVersion != "v4" || n.StartAddress != "192.0.2.0" || n.EndAddress != "192.0.2.255" {
+		t.Fatalf("network: %+v", n)
+	}
+	if len(n.Entities) != 1 || len(n.Entities[0].Roles) != 2 {
+		t.Fatalf("entities: %+v", n.Entities)
+	}
+	if n.Entities[0].Links[0].Href != "https://rdap.example.jp/entity/YY38053JP" {
+		t.Fatalf("entity link: %+v", n.Entities[0].Links)
+	}
+	if len(n.Events) != 2 || n.Events[1].EventDate != "2020-02-14T17:25:06+09:00" {
+		t.Fatalf("events: %+v", n.Events)
+	}
+}
+
+func TestEntity(t *testing.T) {
+	rec := httptest.NewRecorder()
+	testHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/entity/yy38053jp", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var entity struct {
+		Handle     string             `json:"handle"`
+		VCardArray [2]json.RawMessage `json:"vcardArray"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&entity); err != nil {
+		t.Fatal(err)
+	}
+
+	var properties [][]interface{}
+	if err := json.Unmarshal(entity.VCardArray[1], &properties); err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]interface{}{}
+	for _, property := range properties {
+		if _, ok := found[property[0].(string)]; !ok {
+			found[property[0].(string)] = property[3]
+		}
+	}
+	if found["fn"] != "Yamada, Taro" || found["kind"] != "individual" || found["email"] != "taro@example.jp" {
+		t.Fatalf("vcard: %v", found)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	for _, path := range []string{"/ip/198.51.100.1", "/entity/XX00000JP", "/domain/example.jp"} {
+		rec := httptest.NewRecorder()
+		testHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var e Error
+		json.NewDecoder(rec.Body).Decode(&e)
+		if rec.Code != http.StatusNotFound || e.ErrorCode != http.StatusNotFound {
+			t.Errorf("%s: status %d", path, rec.Code)
+		}
+	}
+}
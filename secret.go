@@ -0,0 +1,280 @@
+package jpnic
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// SecretSource は証明書のパスワードやWebトランザクションのパスワードの取得元です。
+// 返されたバイト列は利用後に呼び出し側でゼロクリアするため、呼び出し毎に新しいスライスを返す必要があります。
+type SecretSource interface {
+	Secret() ([]byte, error)
+}
+
+// EnvSecret は環境変数の値を返します。環境変数が設定されていない場合はエラーになります。
+type EnvSecret string
+
+func (e EnvSecret) Secret() ([]byte, error) {
+	value, ok := os.LookupEnv(string(e))
+	if !ok {
+		return nil, fmt.Errorf("環境変数 %s が設定されていません", string(e))
+	}
+	return []byte(value), nil
+}
+
+// FileSecret はファイルの内容(末尾の改行を除く)を返します。
+// 所有者以外が読み書きできるファイル(パーミッションが0600より緩いもの)はエラーになります。
+type FileSecret string
+
+func (f FileSecret) Secret() ([]byte, error) {
+	path := string(f)
+	if err := checkSecretFile(path); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return trimNewline(data), nil
+}
+
+// CommandSecret は外部コマンド(パスワードマネージャ等)の標準出力(末尾の改行を除く)を返します。
+type CommandSecret struct {
+	Name string
+	Args []string
+	// 0の場合は30秒
+	Timeout time.Duration
+}
+
+func (c CommandSecret) Secret() ([]byte, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		zero(out)
+		return nil, fmt.Errorf("%s の実行に失敗しました: %s %s", c.Name, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return trimNewline(out), nil
+}
+
+// EncryptedFileSecret はWriteEncryptedSecretsで作成した暗号化ファイルからNameの値を返します。
+// ファイルはscryptで導出した鍵によるAES-256-GCMで暗号化され、Passphraseで復号します。
+type EncryptedFileSecret struct {
+	Path       string
+	Name       string
+	Passphrase SecretSource
+}
+
+func (e EncryptedFileSecret) Secret() ([]byte, error) {
+	if e.Passphrase == nil {
+		return nil, fmt.Errorf("暗号化ファイルのパスフレーズが設定されていません")
+	}
+	passphrase, err := e.Passphrase.Secret()
+	if err != nil {
+		return nil, err
+	}
+	defer zero(passphrase)
+
+	secrets, err := ReadEncryptedSecrets(e.Path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []byte
+	for name, value := range secrets {
+		if name == e.Name {
+			result = value
+		} else {
+			zero(value)
+		}
+	}
+	if result == nil {
+		return nil, fmt.Errorf("%s に %s が含まれていません", e.Path, e.Name)
+	}
+	return result, nil
+}
+
+type encryptedSecrets struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// scryptのパラメータ
+const (
+	secretsScryptN = 1 << 15
+	secretsScryptR = 8
+	secretsScryptP = 1
+)
+
+// ReadEncryptedSecrets は暗号化ファイルを復号し、名前と値の組を返します。値は利用後にゼロクリアしてください。
+func ReadEncryptedSecrets(path string, passphrase []byte) (map[string][]byte, error) {
+	if err := checkSecretFile(path); err != nil {
+		return nil, err
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file encryptedSecrets
+	if err = json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("暗号化ファイルの形式が不正です: %w", err)
+	}
+
+	gcm, err := secretsCipher(passphrase, file.Salt, file.N, file.R, file.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("暗号化ファイルの形式が不正です")
+	}
+
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, ErrIncorrectPassword
+	}
+	defer zero(plain)
+
+	// 平文は"名前=値"の行で、値はplainとは別の領域にコピーします。
+	secrets := make(map[string][]byte)
+	for _, line := range bytes.Split(plain, []byte("\n")) {
+		i := bytes.IndexByte(line, '=')
+		if i <= 0 {
+			continue
+		}
+		secrets[string(line[:i])] = append([]byte(nil), line[i+1:]...)
+	}
+	return secrets, nil
+}
+
+// WriteEncryptedSecrets は名前と値の組を暗号化してpathに書き込みます。ファイルのパーミッションは0600になります。
+// 名前に"="と改行、値に改行は使えません。
+func WriteEncryptedSecrets(path string, passphrase []byte, secrets map[string][]byte) error {
+	var plain []byte
+	defer func() { zero(plain) }()
+	for name, value := range secrets {
+		if name == "" || bytes.ContainsAny([]byte(name), "=\n") || bytes.ContainsAny(value, "\n") {
+			return fmt.Errorf("名前または値に使えない文字が含まれています: %s", name)
+		}
+		plain = append(plain, name...)
+		plain = append(plain, '=')
+		plain = append(plain, value...)
+		plain = append(plain, '\n')
+	}
+
+	file := encryptedSecrets{
+		N:     secretsScryptN,
+		R:     secretsScryptR,
+		P:     secretsScryptP,
+		Salt:  make([]byte, 16),
+		Nonce: make([]byte, 12),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+
+	gcm, err := secretsCipher(passphrase, file.Salt, file.N, file.R, file.P)
+	if err != nil {
+		return err
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plain, nil)
+
+	raw, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func secretsCipher(passphrase, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, n, r, p, 32)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// checkSecretFile は所有者以外が読み書きできるファイルをエラーにします。Windowsでは確認しません。
+func checkSecretFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%s のパーミッション(%04o)が緩すぎます。0600以下にしてください", path, info.Mode().Perm())
+	}
+	return nil
+}
+
+// resolveSecret はsourceが設定されていればsourceから、無ければvalueを返します。返り値は利用後にzeroでクリアします。
+func resolveSecret(source SecretSource, value string) ([]byte, error) {
+	if source == nil {
+		return []byte(value), nil
+	}
+	return source.Secret()
+}
+
+func trimNewline(data []byte) []byte {
+	return bytes.TrimRight(data, "\r\n")
+}
+
+// zero は秘密情報をメモリ上から消去します。
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
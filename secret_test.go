@@ -0,0 +1,112 @@
+package jpnic
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvSecret(t *testing.T) {
+	os.Setenv("JPNIC_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("JPNIC_TEST_SECRET")
+
+	secret, err := EnvSecret("JPNIC_TEST_SECRET").Secret()
+	if err != nil || string(secret) != "env-secret" {
+		t.Fatalf("%q %v", secret, err)
+	}
+	if _, err = EnvSecret("JPNIC_TEST_SECRET_NOT_FOUND").Secret(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFileSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := ioutil.WriteFile(path, []byte("file-secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := FileSecret(path).Secret(); err == nil {
+		t.Fatal("expected permission error")
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := FileSecret(path).Secret()
+	if err != nil || string(secret) != "file-secret" {
+		t.Fatalf("%q %v", secret, err)
+	}
+}
+
+func TestCommandSecret(t *testing.T) {
+	secret, err := CommandSecret{Name: "echo", Args: []string{"command-secret"}}.Secret()
+	if err != nil || string(secret) != "command-secret" {
+		t.Fatalf("%q %v", secret, err)
+	}
+	if _, err = (CommandSecret{Name: "false"}).Secret(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestEncryptedFileSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	err := WriteEncryptedSecrets(path, []byte("passphrase"), map[string][]byte{
+		"pfx":   []byte("password"),
+		"other": []byte("a=b"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "password") {
+		t.Fatal("secret is stored in plain text")
+	}
+
+	os.Setenv("JPNIC_TEST_PASSPHRASE", "passphrase")
+	defer os.Unsetenv("JPNIC_TEST_PASSPHRASE")
+
+	source := EncryptedFileSecret{Path: path, Name: "other", Passphrase: EnvSecret("JPNIC_TEST_PASSPHRASE")}
+	secret, err := source.Secret()
+	if err != nil || string(secret) != "a=b" {
+		t.Fatalf("%q %v", secret, err)
+	}
+
+	// 暗号化ファイルのパスワードでPKCS#12を読み込む
+	source.Name = "pfx"
+	con := Config{PfxFilePath: "testdata/client-aes.p12", PfxPassSource: source}
+	if _, err = con.loadCertificate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ReadEncryptedSecrets(path, []byte("wrong")); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("expected ErrIncorrectPassword: %v", err)
+	}
+}
+
+func TestEtcMarshalJSON(t *testing.T) {
+	input := WebTransaction{Etc: Etc{CertID: "CERT", Password: "secret"}}
+
+	raw, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "secret") || !strings.Contains(string(raw), `"cert_id":"CERT"`) {
+		t.Fatal(string(raw))
+	}
+
+	var decoded WebTransaction
+	if err = json.Unmarshal([]byte(`{"etc":{"cert_id":"CERT","password":"secret"}}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Etc.Password != "secret" {
+		t.Fatalf("%+v", decoded.Etc)
+	}
+}
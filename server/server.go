@@ -0,0 +1,392 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/homenoc/jpnic-go"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// リクエストBodyの最大サイズ
+const maxBodySize = 1 << 20
+
+// Server は1つの証明書(jpnic.Config)を共有し、JPNICの各操作をJSONのREST APIとして提供します。
+// JPNIC側への同時アクセスを避けるため、全ての操作は直列化されます。
+type Server struct {
+	config *jpnic.Config
+	tokens []string
+	mu     sync.Mutex
+}
+
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type networksV4 struct {
+	Networks []jpnic.InfoIPv4          `json:"networks"`
+	Handles  []jpnic.JPNICHandleDetail `json:"handles"`
+}
+
+type networksV6 struct {
+	Networks []jpnic.InfoIPv6          `json:"networks"`
+	Handles  []jpnic.JPNICHandleDetail `json:"handles"`
+}
+
+type requests struct {
+	Requests []jpnic.RequestInfo `json:"requests"`
+}
+
+type recepNo struct {
+	RecepNo string `json:"recep_no"`
+}
+
+// statusResponse は200以外のステータスでdataを応答する場合にhandleのfnから返します。
+type statusResponse struct {
+	status int
+	data   interface{}
+}
+
+// jpnic.Resultはerror型を含むため、JSON用に変換する
+type transactionResult struct {
+	Error         string   `json:"error,omitempty"`
+	ResultErrors  []string `json:"result_errors,omitempty"`
+	RecepNo       string   `json:"recep_no"`
+	AdmJPNICHdl   string   `json:"adm_jpnic_hdl"`
+	Tech1JPNICHdl string   `json:"tech1_jpnic_hdl"`
+	Tech2JPNICHdl string   `json:"tech2_jpnic_hdl"`
+	Response      string   `json:"response,omitempty"`
+}
+
+// New はServerを作成します。tokensにはAuthorization: Bearerで受け付けるトークンを指定します。
+// トークンが1つも指定されていない場合、全てのリクエストを拒否します。
+func New(config *jpnic.Config, tokens ...string) *Server {
+	return &Server{
+		config: config,
+		tokens: tokens,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="jpnic"`)
+		writeError(w, http.StatusUnauthorized, "unauthorized", "認証に失敗しました")
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	switch {
+	case path == "/v4/networks":
+		s.handle(w, r, http.MethodGet, s.searchIPv4)
+	case path == "/v6/networks":
+		s.handle(w, r, http.MethodGet, s.searchIPv6)
+	case strings.HasPrefix(path, "/handles/"):
+		handle, err := url.PathUnescape(strings.TrimPrefix(path, "/handles/"))
+		if err != nil || handle == "" || strings.Contains(handle, "/") {
+			writeError(w, http.StatusNotFound, "not_found", "JPNICハンドルが不正です")
+			return
+		}
+		// JPNICへのリクエストにそのまま含めるため、ハンドルの形式以外は受け付けない
+		if !jpnic.ValidHandle(handle) {
+			writeError(w, http.StatusBadRequest, "bad_request", "JPNICハンドルの形式が不正です: "+handle)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s.handle(w, r, http.MethodGet, func(r *http.Request) (interface{}, error) {
+				return s.config.GetJPNICHandle(handle)
+			})
+		case http.MethodPut:
+			s.handle(w, r, http.MethodPut, func(r *http.Request) (interface{}, error) {
+				return s.changeHandle(r, handle)
+			})
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPut)
+		}
+	case path == "/requests":
+		s.handle(w, r, http.MethodGet, s.requestList)
+	case strings.HasPrefix(path, "/requests/"):
+		no := strings.TrimPrefix(path, "/requests/")
+		if no == "" || strings.Contains(no, "/") {
+			writeError(w, http.StatusNotFound, "not_found", "受付番号が不正です")
+			return
+		}
+		s.handle(w, r, http.MethodGet, func(r *http.Request) (interface{}, error) {
+			return s.config.GetRequestDetail(no)
+		})
+	case path == "/resource":
+		s.handle(w, r, http.MethodGet, s.resource)
+	case path == "/transactions":
+		s.handle(w, r, http.MethodPost, s.transaction)
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "指定されたパスは存在しません")
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := []byte(strings.TrimPrefix(auth, "Bearer "))
+
+	ok := false
+	for _, t := range s.tokens {
+		if t != "" && subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// handle はメソッドを確認し、JPNICへのアクセスを直列化した上でfnを実行します。
+func (s *Server) handle(w http.ResponseWriter, r *http.Request, method string, fn func(r *http.Request) (interface{}, error)) {
+	if r.Method != method {
+		methodNotAllowed(w, method)
+		return
+	}
+
+	s.mu.Lock()
+	data, err := fn(r)
+	s.mu.Unlock()
+
+	if err != nil {
+		if reqErr, ok := err.(requestError); ok {
+			writeError(w, http.StatusBadRequest, "bad_request", reqErr.Error())
+			return
+		}
+		var maintenanceErr *jpnic.MaintenanceError
+		if errors.As(err, &maintenanceErr) {
+			if wait := time.Until(maintenanceErr.End); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			}
+			writeError(w, http.StatusServiceUnavailable, "maintenance", maintenanceErr.Error())
+			return
+		}
+		var parseErr *jpnic.ParseError
+		if errors.As(err, &parseErr) {
+			// 保存先のパスはサーバ側のログ用のため応答には含めない
+			writeError(w, http.StatusBadGateway, "parse_error", parseErr.Err.Error())
+			return
+		}
+		writeError(w, http.StatusBadGateway, "jpnic_error", err.Error())
+		return
+	}
+
+	if res, ok := data.(statusResponse); ok {
+		writeJSON(w, res.status, res.data)
+		return
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) searchIPv4(r *http.Request) (interface{}, error) {
+	var search jpnic.SearchIPv4
+	if err := decodeQuery(r.URL.Query(), &search); err != nil {
+		return nil, err
+	}
+
+	infos, handles, err := s.config.SearchIPv4(search)
+	if err != nil {
+		return nil, err
+	}
+	return networksV4{Networks: infos, Handles: handles}, nil
+}
+
+func (s *Server) searchIPv6(r *http.Request) (interface{}, error) {
+	var search jpnic.SearchIPv6
+	if err := decodeQuery(r.URL.Query(), &search); err != nil {
+		return nil, err
+	}
+
+	infos, handles, err := s.config.SearchIPv6(search)
+	if err != nil {
+		return nil, err
+	}
+	return networksV6{Networks: infos, Handles: handles}, nil
+}
+
+func (s *Server) changeHandle(r *http.Request, handle string) (interface{}, error) {
+	var input jpnic.JPNICHandleInput
+	if err := decodeBody(r, &input); err != nil {
+		return nil, err
+	}
+	input.JPNICHandle = handle
+
+	no, err := s.config.ChangeUserInfo(input)
+	if err != nil {
+		return nil, err
+	}
+	return recepNo{RecepNo: strings.TrimSpace(no)}, nil
+}
+
+func (s *Server) requestList(r *http.Request) (interface{}, error) {
+	var search jpnic.RequestSearch
+	if err := decodeQuery(r.URL.Query(), &search); err != nil {
+		return nil, err
+	}
+	if recepNo := r.URL.Query().Get("recep_no"); recepNo != "" {
+		search.StartRecepNo = recepNo
+	}
+
+	infos, err := s.config.SearchRequestList(search)
+	if err != nil {
+		return nil, err
+	}
+	return requests{Requests: infos}, nil
+}
+
+func (s *Server) resource(_ *http.Request) (interface{}, error) {
+	info, _, err := s.config.GetResourceManagement()
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *Server) transaction(r *http.Request) (interface{}, error) {
+	var input jpnic.WebTransaction
+	if err := decodeBody(r, &input); err != nil {
+		return nil, err
+	}
+
+	result := s.config.Send(input)
+
+	res := transactionResult{
+		RecepNo:       result.RecepNo,
+		AdmJPNICHdl:   result.AdmJPNICHdl,
+		Tech1JPNICHdl: result.Tech1JPNICHdl,
+		Tech2JPNICHdl: result.Tech2JPNICHdl,
+		Response:      result.Response,
+	}
+	if result.Err != nil {
+		res.Error = result.Err.Error()
+	}
+	for _, resultErr := range result.ResultErr {
+		res.ResultErrors = append(res.ResultErrors, resultErr.Error())
+	}
+	if status := transactionStatus(result); status != http.StatusOK {
+		return statusResponse{status: status, data: res}, nil
+	}
+	return res, nil
+}
+
+// transactionStatus は申請の結果に対応するHTTPステータスを返します。
+// 通信・解析に失敗した場合は502、JPNICが入力内容のエラーを返した場合は422です。
+func transactionStatus(result jpnic.Result) int {
+	switch {
+	case result.Err != nil:
+		return http.StatusBadGateway
+	case len(result.ResultErr) != 0:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusOK
+}
+
+// requestError はクライアント側の入力不備を表します。
+type requestError struct {
+	err error
+}
+
+func (e requestError) Error() string {
+	return e.err.Error()
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return requestError{fmt.Errorf("JSONの解析に失敗しました: %s", err)}
+	}
+	return nil
+}
+
+// decodeQuery はQueryパラメータをjsonタグ名に従って構造体に設定します。
+func decodeQuery(values url.Values, v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		name := strings.Split(rt.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		vals, ok := values[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+
+		field := rv.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(vals[0])
+		case reflect.Bool:
+			b, err := parseBool(vals[0])
+			if err != nil {
+				return requestError{fmt.Errorf("%s: 真偽値が不正です", name)}
+			}
+			field.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(vals[0])
+			if err != nil {
+				return requestError{fmt.Errorf("%s: 数値が不正です", name)}
+			}
+			field.SetInt(int64(n))
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			var list []string
+			for _, val := range vals {
+				list = append(list, strings.Split(val, ",")...)
+			}
+			field.Set(reflect.ValueOf(list))
+		}
+	}
+
+	return nil
+}
+
+func parseBool(str string) (bool, error) {
+	switch strings.ToLower(str) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(str)
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "許可されていないメソッドです")
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorBody{Error: errorDetail{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
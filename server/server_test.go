@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/homenoc/jpnic-go"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUnauthorized(t *testing.T) {
+	s := New(&jpnic.Config{}, "secret")
+
+	for _, auth := range []string{"", "Bearer", "Bearer wrong", "Basic c2VjcmV0"} {
+		req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%q: status %d", auth, rec.Code)
+		}
+
+		var body errorBody
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Error.Code != "unauthorized" {
+			t.Fatalf("%q: code %s", auth, body.Error.Code)
+		}
+	}
+}
+
+func TestNoTokens(t *testing.T) {
+	s := New(&jpnic.Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d", rec.Code)
+	}
+}
+
+func TestRouting(t *testing.T) {
+	s := New(&jpnic.Config{}, "secret")
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/unknown", http.StatusNotFound},
+		{http.MethodPost, "/v4/networks", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/handles/YY38053JP", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/handles/", http.StatusNotFound},
+		{http.MethodGet, "/handles/X%26foo%3Dbar", http.StatusBadRequest},
+		{http.MethodPut, "/handles/YY38053JP%3Fa", http.StatusBadRequest},
+		{http.MethodGet, "/transactions", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/v4/networks?myself=maybe", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestTransactionError(t *testing.T) {
+	// 証明書が無いためSendが失敗し、エラーの内容を含めて502を返す
+	s := New(&jpnic.Config{}, "secret")
+	req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var body transactionResult
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error == "" {
+		t.Fatalf("body: %+v", body)
+	}
+
+	for _, tt := range []struct {
+		result jpnic.Result
+		status int
+	}{
+		{jpnic.Result{RecepNo: "1"}, http.StatusOK},
+		{jpnic.Result{Err: errors.New("failed")}, http.StatusBadGateway},
+		{jpnic.Result{ResultErr: []error{errors.New("ネットワーク名が不正です")}}, http.StatusUnprocessableEntity},
+	} {
+		if status := transactionStatus(tt.result); status != tt.status {
+			t.Errorf("%+v: status %d, want %d", tt.result, status, tt.status)
+		}
+	}
+}
+
+func TestDecodeQuery(t *testing.T) {
+	values := url.Values{
+		"myself":      {"true"},
+		"is_allocate": {"on"},
+		"ryakusho":    {"HOMENOC"},
+		"option_1":    {"YY38053JP,YY36773JP"},
+	}
+
+	var search jpnic.SearchIPv4
+	if err := decodeQuery(values, &search); err != nil {
+		t.Fatal(err)
+	}
+
+	if !search.Myself || !search.IsAllocate || search.IsDetail {
+		t.Fatalf("bool fields: %+v", search)
+	}
+	if search.Ryakusho != "HOMENOC" {
+		t.Fatalf("ryakusho: %s", search.Ryakusho)
+	}
+	if len(search.Option1) != 2 || search.Option1[1] != "YY36773JP" {
+		t.Fatalf("option_1: %v", search.Option1)
+	}
+}
@@ -0,0 +1,247 @@
+package snapshot
+
+import (
+	"fmt"
+	"github.com/homenoc/jpnic-go"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Report は2つのSnapshotの差分です。
+type Report struct {
+	From              time.Time                 `json:"from"`
+	To                time.Time                 `json:"to"`
+	NetworksAdded     []jpnic.InfoDetail        `json:"networks_added"`
+	NetworksReturned  []jpnic.InfoDetail        `json:"networks_returned"`
+	NetworksModified  []NetworkChange           `json:"networks_modified"`
+	HandlesChanged    []HandleChange            `json:"handles_changed"`
+	CIDRBlocksAdded   []jpnic.ResourceCIDRBlock `json:"cidr_blocks_added"`
+	CIDRBlocksRemoved []jpnic.ResourceCIDRBlock `json:"cidr_blocks_removed"`
+	CIDRBlocksChanged []CIDRBlockChange         `json:"cidr_blocks_changed"`
+}
+
+// FieldChange は1項目の変更です。Fieldにはjsonタグ名が入ります。
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type NetworkChange struct {
+	IPAddress   string        `json:"ip_address"`
+	NetworkName string        `json:"network_name"`
+	Changes     []FieldChange `json:"changes"`
+}
+
+type HandleChange struct {
+	JPNICHandle string `json:"jpnic_handle"`
+	Added       bool   `json:"added"`
+	Removed     bool   `json:"removed"`
+	// 最終更新日時が変わっているか(falseで項目が変わっている場合、JPNIC側の表示が変わった可能性があります)
+	UpdateDateChanged bool          `json:"update_date_changed"`
+	Changes           []FieldChange `json:"changes"`
+}
+
+type CIDRBlockChange struct {
+	Address string        `json:"address"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// Diff はoldからnewへの変更を返します。ネットワークはIPネットワークアドレス、ハンドルはJPNICハンドル、CIDRブロックはアドレスで対応付けます。
+func Diff(old, new *Snapshot) *Report {
+	r := &Report{From: old.Time, To: new.Time}
+
+	// Network
+	oldNetworks := make(map[string]jpnic.InfoDetail)
+	for _, detail := range old.Networks() {
+		oldNetworks[networkKey(detail.IPAddress)] = detail
+	}
+	newNetworks := make(map[string]bool)
+	for _, detail := range new.Networks() {
+		key := networkKey(detail.IPAddress)
+		newNetworks[key] = true
+
+		oldDetail, ok := oldNetworks[key]
+		switch {
+		case !ok:
+			r.NetworksAdded = append(r.NetworksAdded, detail)
+		case oldDetail.ReturnDate == "" && detail.ReturnDate != "":
+			r.NetworksReturned = append(r.NetworksReturned, detail)
+		default:
+			if changes := compareFields(oldDetail, detail); len(changes) != 0 {
+				r.NetworksModified = append(r.NetworksModified, NetworkChange{
+					IPAddress:   detail.IPAddress,
+					NetworkName: detail.NetworkName,
+					Changes:     changes,
+				})
+			}
+		}
+	}
+	for _, detail := range old.Networks() {
+		if !newNetworks[networkKey(detail.IPAddress)] && detail.ReturnDate == "" {
+			r.NetworksReturned = append(r.NetworksReturned, detail)
+		}
+	}
+
+	// JPNIC Handle
+	oldHandles := make(map[string]jpnic.JPNICHandleDetail)
+	for _, handle := range old.Handles {
+		oldHandles[strings.ToUpper(handle.JPNICHandle)] = handle
+	}
+	newHandles := make(map[string]bool)
+	for _, handle := range new.Handles {
+		key := strings.ToUpper(handle.JPNICHandle)
+		newHandles[key] = true
+
+		oldHandle, ok := oldHandles[key]
+		if !ok {
+			r.HandlesChanged = append(r.HandlesChanged, HandleChange{JPNICHandle: handle.JPNICHandle, Added: true})
+			continue
+		}
+		changes := compareFields(oldHandle, handle)
+		if len(changes) == 0 {
+			continue
+		}
+		r.HandlesChanged = append(r.HandlesChanged, HandleChange{
+			JPNICHandle:       handle.JPNICHandle,
+			UpdateDateChanged: oldHandle.UpdateDate != handle.UpdateDate,
+			Changes:           changes,
+		})
+	}
+	for _, handle := range old.Handles {
+		if !newHandles[strings.ToUpper(handle.JPNICHandle)] {
+			r.HandlesChanged = append(r.HandlesChanged, HandleChange{JPNICHandle: handle.JPNICHandle, Removed: true})
+		}
+	}
+
+	// CIDR Block
+	var oldBlocks, newBlocks []jpnic.ResourceCIDRBlock
+	if old.Resource != nil {
+		oldBlocks = old.Resource.ResourceCIDRBlock
+	}
+	if new.Resource != nil {
+		newBlocks = new.Resource.ResourceCIDRBlock
+	}
+	oldBlockMap := make(map[string]jpnic.ResourceCIDRBlock)
+	for _, block := range oldBlocks {
+		oldBlockMap[networkKey(block.Address)] = block
+	}
+	newBlockMap := make(map[string]bool)
+	for _, block := range newBlocks {
+		key := networkKey(block.Address)
+		newBlockMap[key] = true
+
+		oldBlock, ok := oldBlockMap[key]
+		if !ok {
+			r.CIDRBlocksAdded = append(r.CIDRBlocksAdded, block)
+			continue
+		}
+		if changes := compareFields(oldBlock, block); len(changes) != 0 {
+			r.CIDRBlocksChanged = append(r.CIDRBlocksChanged, CIDRBlockChange{Address: block.Address, Changes: changes})
+		}
+	}
+	for _, block := range oldBlocks {
+		if !newBlockMap[networkKey(block.Address)] {
+			r.CIDRBlocksRemoved = append(r.CIDRBlocksRemoved, block)
+		}
+	}
+
+	return r
+}
+
+// Empty は差分が無い場合にtrueを返します。
+func (r *Report) Empty() bool {
+	return len(r.NetworksAdded) == 0 && len(r.NetworksReturned) == 0 && len(r.NetworksModified) == 0 &&
+		len(r.HandlesChanged) == 0 && len(r.CIDRBlocksAdded) == 0 && len(r.CIDRBlocksRemoved) == 0 &&
+		len(r.CIDRBlocksChanged) == 0
+}
+
+// String は差分を読みやすいテキストで返します。
+func (r *Report) String() string {
+	var b strings.Builder
+
+	const layout = "2006/01/02 15:04:05"
+	fmt.Fprintf(&b, "%s -> %s\n", r.From.Local().Format(layout), r.To.Local().Format(layout))
+
+	if r.Empty() {
+		b.WriteString("変更はありません\n")
+		return b.String()
+	}
+
+	for _, detail := range r.NetworksAdded {
+		fmt.Fprintf(&b, "[追加] %s %s\n", detail.IPAddress, detail.NetworkName)
+	}
+	for _, detail := range r.NetworksReturned {
+		fmt.Fprintf(&b, "[返却] %s %s\n", detail.IPAddress, detail.NetworkName)
+	}
+	for _, change := range r.NetworksModified {
+		fmt.Fprintf(&b, "[変更] %s %s\n", change.IPAddress, change.NetworkName)
+		writeChanges(&b, change.Changes)
+	}
+	for _, change := range r.HandlesChanged {
+		switch {
+		case change.Added:
+			fmt.Fprintf(&b, "[ハンドル追加] %s\n", change.JPNICHandle)
+		case change.Removed:
+			fmt.Fprintf(&b, "[ハンドル削除] %s\n", change.JPNICHandle)
+		case !change.UpdateDateChanged:
+			fmt.Fprintf(&b, "[ハンドル変更] %s (最終更新日時は変わっていません)\n", change.JPNICHandle)
+			writeChanges(&b, change.Changes)
+		default:
+			fmt.Fprintf(&b, "[ハンドル変更] %s\n", change.JPNICHandle)
+			writeChanges(&b, change.Changes)
+		}
+	}
+	for _, block := range r.CIDRBlocksAdded {
+		fmt.Fprintf(&b, "[CIDRブロック追加] %s\n", block.Address)
+	}
+	for _, block := range r.CIDRBlocksRemoved {
+		fmt.Fprintf(&b, "[CIDRブロック削除] %s\n", block.Address)
+	}
+	for _, change := range r.CIDRBlocksChanged {
+		fmt.Fprintf(&b, "[CIDRブロック変更] %s\n", change.Address)
+		writeChanges(&b, change.Changes)
+	}
+
+	return b.String()
+}
+
+func writeChanges(b *strings.Builder, changes []FieldChange) {
+	for _, change := range changes {
+		fmt.Fprintf(b, "    %s: %q -> %q\n", change.Field, change.Old, change.New)
+	}
+}
+
+// compareFields は同じ型の構造体の各項目を比較します。リンク(URL)はセッション毎に変わり得るため比較しません。
+func compareFields(old, new interface{}) []FieldChange {
+	oldValue := reflect.ValueOf(old)
+	newValue := reflect.ValueOf(new)
+	t := oldValue.Type()
+
+	var changes []FieldChange
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.HasSuffix(field.Name, "Link") || field.Name == "URL" {
+			continue
+		}
+
+		oldStr := fmt.Sprint(oldValue.Field(i).Interface())
+		newStr := fmt.Sprint(newValue.Field(i).Interface())
+		if oldStr == newStr {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		changes = append(changes, FieldChange{Field: name, Old: oldStr, New: newStr})
+	}
+	return changes
+}
+
+// networkKey はアドレス表記の揺れ(空白・大文字小文字)を吸収します。
+func networkKey(address string) string {
+	return strings.ToLower(strings.Join(strings.Fields(address), ""))
+}
@@ -0,0 +1,76 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"github.com/homenoc/jpnic-go"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	old := &Snapshot{
+		Time: time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC),
+		IPv4: []jpnic.InfoIPv4{
+			{IPAddress: "192.0.2.0/26", InfoDetail: jpnic.InfoDetail{NetworkName: "NET-A", NameServer: "ns1.example.jp"}},
+			{IPAddress: "192.0.2.64/26", NetworkName: "NET-B"},
+			{IPAddress: "192.0.2.128/26", NetworkName: "NET-C"},
+		},
+		Handles: []jpnic.JPNICHandleDetail{
+			{JPNICHandle: "YY38053JP", Email: "old@example.jp", UpdateDate: "2021/08/01"},
+			{JPNICHandle: "YY36773JP", Tel: "03-0000-0000", UpdateDate: "2021/08/01"},
+		},
+		Resource: &jpnic.ResourceInfo{ResourceCIDRBlock: []jpnic.ResourceCIDRBlock{
+			{Address: "192.0.2.0/24", UsedAddress: 192, AllAddress: 256},
+		}},
+	}
+	new := &Snapshot{
+		Time: time.Date(2021, 9, 2, 0, 0, 0, 0, time.UTC),
+		IPv4: []jpnic.InfoIPv4{
+			{IPAddress: "192.0.2.0/26", InfoDetail: jpnic.InfoDetail{NetworkName: "NET-A", NameServer: "ns2.example.jp"}},
+			{IPAddress: "192.0.2.64/26", NetworkName: "NET-B", ReturnDate: "2021/09/01"},
+			{IPAddress: "192.0.2.192/26", NetworkName: "NET-D"},
+		},
+		Handles: []jpnic.JPNICHandleDetail{
+			{JPNICHandle: "YY38053JP", Email: "new@example.jp", UpdateDate: "2021/09/01"},
+			{JPNICHandle: "YY36773JP", Tel: "03-1111-1111", UpdateDate: "2021/08/01"},
+		},
+		Resource: &jpnic.ResourceInfo{ResourceCIDRBlock: []jpnic.ResourceCIDRBlock{
+			{Address: "192.0.2.0/24", UsedAddress: 256, AllAddress: 256},
+			{Address: "198.51.100.0/24", AllAddress: 256},
+		}},
+	}
+
+	r := Diff(old, new)
+
+	if len(r.NetworksAdded) != 1 || r.NetworksAdded[0].NetworkName != "NET-D" {
+		t.Errorf("added: %+v", r.NetworksAdded)
+	}
+	if len(r.NetworksReturned) != 2 || r.NetworksReturned[0].NetworkName != "NET-B" || r.NetworksReturned[1].NetworkName != "NET-C" {
+		t.Errorf("returned: %+v", r.NetworksReturned)
+	}
+	if len(r.NetworksModified) != 1 || r.NetworksModified[0].Changes[0] != (FieldChange{"name_server", "ns1.example.jp", "ns2.example.jp"}) {
+		t.Errorf("modified: %+v", r.NetworksModified)
+	}
+	if len(r.HandlesChanged) != 2 || !r.HandlesChanged[0].UpdateDateChanged || r.HandlesChanged[1].UpdateDateChanged {
+		t.Errorf("handles: %+v", r.HandlesChanged)
+	}
+	if len(r.CIDRBlocksAdded) != 1 || len(r.CIDRBlocksChanged) != 1 || len(r.CIDRBlocksRemoved) != 0 {
+		t.Errorf("cidr blocks: %+v", r)
+	}
+
+	text := r.String()
+	for _, want := range []string{"[追加] 192.0.2.192/26 NET-D", "[返却] 192.0.2.128/26 NET-C", `name_server: "ns1.example.jp" -> "ns2.example.jp"`, "YY36773JP (最終更新日時は変わっていません)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in\n%s", want, text)
+		}
+	}
+
+	if _, err := json.Marshal(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if !Diff(new, new).Empty() {
+		t.Error("same snapshot should be empty")
+	}
+}
@@ -0,0 +1,85 @@
+package snapshot
+
+import (
+	"github.com/homenoc/jpnic-go"
+	"strings"
+)
+
+// Index はSnapshotをIPアドレス・プレフィックス・ネットワーク名・JPNICハンドルで検索するための索引です。
+type Index struct {
+	networks []indexedNetwork
+	handles  map[string]jpnic.JPNICHandleDetail
+}
+
+type indexedNetwork struct {
+	network network
+	detail  jpnic.InfoDetail
+}
+
+func NewIndex(s *Snapshot) *Index {
+	index := &Index{handles: make(map[string]jpnic.JPNICHandleDetail)}
+
+	for _, detail := range s.Networks() {
+		n, ok := parseNetwork(detail.IPAddress)
+		if !ok {
+			continue
+		}
+		index.networks = append(index.networks, indexedNetwork{network: n, detail: detail})
+	}
+
+	for _, handle := range s.Handles {
+		index.handles[strings.ToUpper(handle.JPNICHandle)] = handle
+	}
+
+	return index
+}
+
+// IsAddress はqueryがIPアドレスまたはプレフィックスとして解釈できるかを返します。
+func IsAddress(query string) bool {
+	_, ok := parseNetwork(query)
+	return ok
+}
+
+// Network はIPアドレスまたはプレフィックスに完全一致するネットワーク、無ければそれを含む最も狭いネットワークを返します。
+func (i *Index) Network(query string) (jpnic.InfoDetail, bool) {
+	n, ok := parseNetwork(query)
+	if !ok {
+		return jpnic.InfoDetail{}, false
+	}
+
+	var result *indexedNetwork
+
+	for idx, indexed := range i.networks {
+		if indexed.network.equal(n) {
+			return indexed.detail, true
+		}
+		if !indexed.network.contains(n) {
+			continue
+		}
+		if result == nil || indexed.network.smaller(result.network) {
+			result = &i.networks[idx]
+		}
+	}
+
+	if result == nil {
+		return jpnic.InfoDetail{}, false
+	}
+	return result.detail, true
+}
+
+// NetworksByName はネットワーク名が一致する(大文字小文字は区別しない)ネットワークを返します。
+func (i *Index) NetworksByName(name string) []jpnic.InfoDetail {
+	var details []jpnic.InfoDetail
+	for _, indexed := range i.networks {
+		if strings.EqualFold(indexed.detail.NetworkName, name) {
+			details = append(details, indexed.detail)
+		}
+	}
+	return details
+}
+
+// Handle はJPNICハンドル(グループハンドル)を大文字小文字を区別せずに検索します。
+func (i *Index) Handle(handle string) (jpnic.JPNICHandleDetail, bool) {
+	detail, ok := i.handles[strings.ToUpper(handle)]
+	return detail, ok
+}
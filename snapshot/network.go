@@ -0,0 +1,80 @@
+package snapshot
+
+import (
+	"bytes"
+	"net"
+	"strings"
+)
+
+// network はIPネットワークアドレスを開始・終了アドレスの範囲として扱います。
+type network struct {
+	start net.IP
+	end   net.IP
+}
+
+// parseNetwork は"192.0.2.0/24"形式と"192.0.2.0 - 192.0.2.255"形式を解釈します。
+func parseNetwork(str string) (network, bool) {
+	str = strings.TrimSpace(str)
+
+	if _, ipNet, err := net.ParseCIDR(str); err == nil {
+		start := ipNet.IP.To16()
+		end := make(net.IP, len(start))
+		mask := ipNet.Mask
+		if len(mask) == net.IPv4len {
+			mask = append(net.CIDRMask(96, 128)[:12], mask...)
+		}
+		for i := range start {
+			end[i] = start[i] | ^mask[i]
+		}
+		return network{start: start, end: end}, true
+	}
+
+	if split := strings.Split(str, "-"); len(split) == 2 {
+		start := net.ParseIP(strings.TrimSpace(split[0]))
+		end := net.ParseIP(strings.TrimSpace(split[1]))
+		if start != nil && end != nil && bytes.Compare(start.To16(), end.To16()) <= 0 {
+			return network{start: start.To16(), end: end.To16()}, true
+		}
+	}
+
+	if ip := net.ParseIP(str); ip != nil {
+		return network{start: ip.To16(), end: ip.To16()}, true
+	}
+
+	return network{}, false
+}
+
+// ParseNetwork はparseNetworkと同じ形式を開始・終了アドレスに変換します。
+// アドレスは16バイト形式のため、IPv4かどうかはTo4で判定します。
+func ParseNetwork(str string) (start, end net.IP, ok bool) {
+	n, ok := parseNetwork(str)
+	return n.start, n.end, ok
+}
+
+func (n network) contains(other network) bool {
+	return bytes.Compare(n.start, other.start) <= 0 && bytes.Compare(other.end, n.end) <= 0
+}
+
+func (n network) equal(other network) bool {
+	return n.start.Equal(other.start) && n.end.Equal(other.end)
+}
+
+// smaller はnがotherより狭い範囲の場合にtrueを返します。
+func (n network) smaller(other network) bool {
+	return bytes.Compare(diff(n), diff(other)) < 0
+}
+
+func diff(n network) []byte {
+	result := make([]byte, len(n.end))
+	borrow := 0
+	for i := len(n.end) - 1; i >= 0; i-- {
+		d := int(n.end[i]) - int(n.start[i]) - borrow
+		borrow = 0
+		if d < 0 {
+			d += 256
+			borrow = 1
+		}
+		result[i] = byte(d)
+	}
+	return result
+}
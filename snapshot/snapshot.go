@@ -0,0 +1,186 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"github.com/homenoc/jpnic-go"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snapshot はJPNICから同期した登録情報の写しです。
+type Snapshot struct {
+	Time    time.Time                 `json:"time"`
+	IPv4    []jpnic.InfoIPv4          `json:"ipv4"`
+	IPv6    []jpnic.InfoIPv6          `json:"ipv6"`
+	Handles []jpnic.JPNICHandleDetail `json:"handles"`
+	// 申請一覧
+	Requests []jpnic.RequestInfo `json:"requests"`
+	// 資源管理者情報
+	Resource *jpnic.ResourceInfo `json:"resource,omitempty"`
+}
+
+// Fetch は自組織のIPv4/IPv6の登録情報を詳細情報・JPNICハンドル込みで取得します。
+// v4/v6のどちらかがnilの場合、そのバージョンは取得しません。申請一覧と資源管理者情報はv4(無ければv6)で取得します。
+func Fetch(v4, v6 *jpnic.Config) (*Snapshot, error) {
+	s := &Snapshot{Time: time.Now()}
+
+	if v4 != nil {
+		infos, handles, err := v4.SearchIPv4(jpnic.SearchIPv4{Myself: true, IsDetail: true})
+		if err != nil {
+			return nil, err
+		}
+		s.IPv4 = infos
+		s.addHandles(handles)
+	}
+
+	if v6 != nil {
+		infos, handles, err := v6.SearchIPv6(jpnic.SearchIPv6{Myself: true, IsDetail: true})
+		if err != nil {
+			return nil, err
+		}
+		s.IPv6 = infos
+		s.addHandles(handles)
+	}
+
+	con := v4
+	if con == nil {
+		con = v6
+	}
+	if con != nil {
+		requests, err := con.GetRequestList("")
+		if err != nil {
+			return nil, err
+		}
+		s.Requests = requests
+
+		resource, _, err := con.GetResourceManagement()
+		if err != nil {
+			return nil, err
+		}
+		s.Resource = &resource
+	}
+
+	return s, nil
+}
+
+// Load はSaveで保存したファイルを読み込みます。
+func Load(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return decode(file)
+}
+
+func decode(r io.Reader) (*Snapshot, error) {
+	var s Snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Save はファイルに保存します。読み込み中のプロセスが壊れたファイルを読まないよう、一時ファイルを経由します。
+func (s *Snapshot) Save(path string) error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, raw)
+}
+
+func writeFile(path string, raw []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Networks はIPv4/IPv6の詳細情報を返します。詳細情報が取得できていない項目は一覧の値で補います。
+func (s *Snapshot) Networks() []jpnic.InfoDetail {
+	var details []jpnic.InfoDetail
+
+	for _, info := range s.IPv4 {
+		details = append(details, IPv4Detail(info))
+	}
+	for _, info := range s.IPv6 {
+		details = append(details, IPv6Detail(info))
+	}
+
+	return details
+}
+
+// IPv4Detail は詳細情報の空の項目を検索結果の一覧の値で補ったInfoDetailを返します。
+func IPv4Detail(info jpnic.InfoIPv4) jpnic.InfoDetail {
+	detail := info.InfoDetail
+	fill(&detail.IPAddress, info.IPAddress)
+	fill(&detail.NetworkName, info.NetworkName)
+	fill(&detail.Org, info.OrgName)
+	fill(&detail.Ryakusho, info.Ryakusho)
+	fill(&detail.RecepNo, info.RecepNo)
+	fill(&detail.DeliNo, info.DeliNo)
+	fill(&detail.AssignDate, info.AssignDate)
+	fill(&detail.ReturnDate, info.ReturnDate)
+	fill(&detail.Type, info.Type)
+	return detail
+}
+
+// IPv6Detail は詳細情報の空の項目を検索結果の一覧の値で補ったInfoDetailを返します。
+func IPv6Detail(info jpnic.InfoIPv6) jpnic.InfoDetail {
+	detail := info.InfoDetail
+	fill(&detail.IPAddress, info.IPAddress)
+	fill(&detail.NetworkName, info.NetworkName)
+	fill(&detail.Org, info.OrgName)
+	fill(&detail.Ryakusho, info.Ryakusho)
+	fill(&detail.RecepNo, info.RecepNo)
+	fill(&detail.DeliNo, info.DeliNo)
+	fill(&detail.AssignDate, info.AssignDate)
+	fill(&detail.ReturnDate, info.ReturnDate)
+	return detail
+}
+
+// Handle はJPNICハンドル(グループハンドル)の情報を返します。
+func (s *Snapshot) Handle(handle string) (jpnic.JPNICHandleDetail, bool) {
+	for _, detail := range s.Handles {
+		if strings.EqualFold(detail.JPNICHandle, handle) {
+			return detail, true
+		}
+	}
+	return jpnic.JPNICHandleDetail{}, false
+}
+
+func (s *Snapshot) addHandles(handles []jpnic.JPNICHandleDetail) {
+	for _, handle := range handles {
+		if handle.JPNICHandle == "" {
+			continue
+		}
+		if _, ok := s.Handle(handle.JPNICHandle); ok {
+			continue
+		}
+		s.Handles = append(s.Handles, handle)
+	}
+}
+
+func fill(dst *string, src string) {
+	if *dst == "" {
+		*dst = src
+	}
+}
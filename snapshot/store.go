@@ -0,0 +1,131 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound は指定した日時以前のSnapshotが存在しない場合に返されます。
+var ErrNotFound = errors.New("snapshotが見つかりません")
+
+// Store は同期したSnapshotを日時付きで永続化します。
+type Store interface {
+	// Save はSnapshotを保存します。保存日時にはSnapshot.Timeが使われます。
+	Save(s *Snapshot) error
+	// At は日時t時点の状態(t以前で最新のSnapshot)を返します。
+	At(t time.Time) (*Snapshot, error)
+	// Latest は最新のSnapshotを返します。
+	Latest() (*Snapshot, error)
+	// List は保存済みのSnapshotの日時を古い順に返します。
+	List() ([]time.Time, error)
+}
+
+const (
+	fileTimeFormat = "20060102T150405.000000000Z"
+	fileExt        = ".json.gz"
+)
+
+// FileStore はディレクトリ内にSnapshot毎のgzip圧縮したJSONファイルとして保存するStoreです。
+type FileStore struct {
+	Dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+func (f *FileStore) Save(s *Snapshot) error {
+	if s.Time.IsZero() {
+		return errors.New("snapshotの日時が設定されていません")
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(s); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return writeFile(f.path(s.Time), buf.Bytes())
+}
+
+func (f *FileStore) At(t time.Time) (*Snapshot, error) {
+	times, err := f.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// t以前で最新のもの
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(t) })
+	if i == 0 {
+		return nil, ErrNotFound
+	}
+	return f.load(times[i-1])
+}
+
+func (f *FileStore) Latest() (*Snapshot, error) {
+	times, err := f.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(times) == 0 {
+		return nil, ErrNotFound
+	}
+	return f.load(times[len(times)-1])
+}
+
+func (f *FileStore) List() ([]time.Time, error) {
+	files, err := ioutil.ReadDir(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var times []time.Time
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+		t, err := time.Parse(fileTimeFormat, strings.TrimSuffix(name, fileExt))
+		if err != nil {
+			continue
+		}
+		times = append(times, t)
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times, nil
+}
+
+func (f *FileStore) load(t time.Time) (*Snapshot, error) {
+	file, err := os.Open(f.path(t))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return decode(zr)
+}
+
+func (f *FileStore) path(t time.Time) string {
+	return filepath.Join(f.Dir, t.UTC().Format(fileTimeFormat)+fileExt)
+}
@@ -0,0 +1,77 @@
+package snapshot
+
+import (
+	"github.com/homenoc/jpnic-go"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jpnic-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.Latest(); err != ErrNotFound {
+		t.Fatalf("empty store: %v", err)
+	}
+
+	base := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"NET-A", "NET-B", "NET-C"} {
+		err = store.Save(&Snapshot{
+			Time:     base.AddDate(0, 0, i),
+			IPv4:     []jpnic.InfoIPv4{{IPAddress: "192.0.2.0/24", NetworkName: name}},
+			Requests: []jpnic.RequestInfo{{RecepNo: "020210901000001"}},
+			Resource: &jpnic.ResourceInfo{UtilizationRatio: float64(i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	times, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 3 || !times[0].Equal(base) {
+		t.Fatalf("list: %v", times)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{base, "NET-A"},
+		{base.Add(36 * time.Hour), "NET-B"},
+		{base.AddDate(1, 0, 0), "NET-C"},
+	}
+	for _, tt := range tests {
+		s, err := store.At(tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.IPv4[0].NetworkName != tt.want {
+			t.Errorf("%s: %s, want %s", tt.at, s.IPv4[0].NetworkName, tt.want)
+		}
+	}
+
+	if _, err = store.At(base.Add(-time.Second)); err != ErrNotFound {
+		t.Fatalf("before first snapshot: %v", err)
+	}
+
+	s, err := store.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Resource == nil || s.Resource.UtilizationRatio != 2 || len(s.Requests) != 1 {
+		t.Fatalf("latest: %+v", s)
+	}
+}
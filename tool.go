@@ -1,15 +1,18 @@
 package jpnic
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/text/transform"
-	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
+	"time"
 )
 
 func randomStr() (string, error) {
@@ -27,10 +30,11 @@ func randomStr() (string, error) {
 	return result, nil
 }
 
-func readEUCJP(readerInput io.Reader) (string, []byte, error) {
-	// euc-jp => utf-8
-	reader := transform.NewReader(readerInput, japanese.EUCJP.NewDecoder())
-	strByte, err := ioutil.ReadAll(reader)
+func toShiftJIS(str string) (string, []byte, error) {
+	// utf-8 => shift-jis(CP932)
+	iostr := strings.NewReader(cp932Replacer.Replace(str))
+	rio := transform.NewReader(iostr, japanese.ShiftJIS.NewEncoder())
+	strByte, err := ioutil.ReadAll(rio)
 	if err != nil {
 		return "", nil, err
 
@@ -38,31 +42,39 @@ func readEUCJP(readerInput io.Reader) (string, []byte, error) {
 	return string(strByte), strByte, nil
 }
 
-func readShiftJIS(readerInput io.Reader) (string, []byte, error) {
-	// shift-jis => utf-8
-	reader := transform.NewReader(readerInput, japanese.ShiftJIS.NewDecoder())
-	strByte, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return "", nil, err
-
+// encodeForm はキーと値を交互に並べたpairsを、値をShift_JIS(CP932)に変換してURLエンコードしたフォームの本文にします。
+// 値に&・=・+・%・空白等が含まれていても項目が壊れないようにするためです。(項目の順序は維持します)
+func encodeForm(pairs ...string) (string, error) {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		_, value, err := toShiftJIS(pairs[i+1])
+		if err != nil {
+			return "", err
+		}
+		if i != 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(pairs[i] + "=" + url.QueryEscape(string(value)))
 	}
-	return string(strByte), strByte, nil
+	return b.String(), nil
 }
 
-func toShiftJIS(str string) (string, []byte, error) {
-	// utf-8 => shift-jis
-	iostr := strings.NewReader(str)
-	rio := transform.NewReader(iostr, japanese.ShiftJIS.NewEncoder())
-	strByte, err := ioutil.ReadAll(rio)
-	if err != nil {
-		return "", nil, err
-
+// sleepContext はdの間待ちます。待機中にctxがキャンセルされた場合はctx.Err()を返します。
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
 	}
-	return string(strByte), strByte, nil
 }
 
-func getLink(client *http.Client, menuURL, str string) (string, error) {
+func getLink(ctx context.Context, client *http.Client, menuURL, str string) (link string, err error) {
+	ctx, span := startSpan(ctx, "menu", Attr("jpnic.menu", str))
+	defer func() { endSpan(span, err) }()
+
 	r := request{
+		Context:     ctx,
 		Client:      client,
 		URL:         baseURL + "/jpnic/" + menuURL,
 		UserAgent:   userAgent,
@@ -75,7 +87,7 @@ func getLink(client *http.Client, menuURL, str string) (string, error) {
 	}
 	defer resp.Body.Close()
 
-	body, _, err := readShiftJIS(resp.Body)
+	body, _, err := readHTML(resp)
 	if err != nil {
 		return "", err
 	}
@@ -107,12 +119,96 @@ func getLink(client *http.Client, menuURL, str string) (string, error) {
 	})
 
 	if url == "" {
-		return "", fmt.Errorf("項目が見つかりません")
+		return "", newParseError(ctx, "menu", r.URL, body, fmt.Errorf("項目が見つかりません"))
 	}
 
 	return url, nil
 }
 
+var recepNoRegexp = regexp.MustCompile(`^[0-9]+$`)
+
+// parseRequestList は申請一覧の表から申請情報を取得します。受付番号が数字でない行(見出し等)は読み飛ばします。
+func parseRequestList(doc *goquery.Document) []RequestInfo {
+	var infos []RequestInfo
+
+	doc.Find("tr").Each(func(_ int, rowHtml *goquery.Selection) {
+		cells := rowHtml.ChildrenFiltered("td")
+		if cells.Length() < 8 {
+			return
+		}
+
+		var info RequestInfo
+		cells.Each(func(index int, tableHtml *goquery.Selection) {
+			dataStr := strings.TrimSpace(tableHtml.Text())
+			switch index {
+			case 0:
+				info.RecepNo = dataStr
+				if href, ok := tableHtml.Find("a").Attr("href"); ok {
+					info.DetailLink = href
+				}
+			case 1:
+				info.DeliNo = dataStr
+			case 2:
+				info.ApplyKind = dataStr
+			case 3:
+				info.ApplyClass = dataStr
+			case 4:
+				info.Applicant = dataStr
+			case 5:
+				info.ApplyDate = dataStr
+			case 6:
+				info.CompleteDate = dataStr
+			case 7:
+				info.Status = dataStr
+			}
+		})
+
+		if !recepNoRegexp.MatchString(info.RecepNo) {
+			return
+		}
+		infos = append(infos, info)
+	})
+
+	return infos
+}
+
+// getNextPageLink は一覧の「次へ」のリンク先を返します。存在しない場合は空文字を返します。
+func getNextPageLink(doc *goquery.Document) string {
+	var link string
+
+	doc.Find("a").EachWithBreak(func(_ int, aHtml *goquery.Selection) bool {
+		dataStr := strings.TrimSpace(aHtml.Text())
+		if !strings.HasPrefix(dataStr, "次") {
+			return true
+		}
+		href, ok := aHtml.Attr("href")
+		if !ok || strings.HasPrefix(href, "javascript") {
+			return true
+		}
+		link = resolveURL(href)
+		return false
+	})
+
+	return link
+}
+
+// resolveURL はJPNICのページ内のリンクを絶対URLにします。
+func resolveURL(href string) string {
+	switch {
+	case strings.HasPrefix(href, "http://"), strings.HasPrefix(href, "https://"):
+		return href
+	case strings.HasPrefix(href, "/"):
+		return baseURL + href
+	}
+	return baseURL + "/jpnic/" + href
+}
+
+func fillString(dst *string, src string) {
+	if *dst == "" {
+		*dst = src
+	}
+}
+
 func getSearchBoolean(isFilter bool) string {
 	if isFilter {
 		return "on"
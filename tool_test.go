@@ -0,0 +1,59 @@
+package jpnic
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"strings"
+	"testing"
+)
+
+func TestParseRequestList(t *testing.T) {
+	html := `<html><body><table><tr><td>
+<table>
+<tr><td>受付番号</td><td>審議番号</td><td>申請種別</td><td>申請区分</td><td>申請者</td><td>申請日</td><td>完了日</td><td>ステータス</td></tr>
+<tr><td><a href="recepdetail.do?recep_no=020210901000001">020210901000001</a></td><td></td><td>IPv4割り当て報告</td><td>新規</td><td>山田 太郎</td><td>2021/09/01</td><td>2021/09/02</td><td>完了</td></tr>
+<tr><td>020210901000002</td><td></td><td>担当グループ（担当者）情報登録・変更</td><td>変更</td><td>山田 太郎</td><td>2021/09/01</td><td></td><td>審議中</td></tr>
+</table>
+<a href="requestlist.do?page=2">次へ</a>
+</td></tr></table></body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos := parseRequestList(doc)
+	if len(infos) != 2 {
+		t.Fatalf("infos: %+v", infos)
+	}
+	if infos[0].RecepNo != "020210901000001" || infos[0].Status != "完了" || infos[1].ApplyClass != "変更" {
+		t.Fatalf("infos: %+v", infos)
+	}
+
+	if link := getNextPageLink(doc); link != baseURL+"/jpnic/requestlist.do?page=2" {
+		t.Fatalf("next link: %s", link)
+	}
+}
+
+func TestParseRequestListEmpty(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table><tr><td>該当する申請はありません</td></tr></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if infos := parseRequestList(doc); len(infos) != 0 {
+		t.Fatalf("infos: %+v", infos)
+	}
+	if link := getNextPageLink(doc); link != "" {
+		t.Fatalf("next link: %s", link)
+	}
+}
+
+func TestEncodeForm(t *testing.T) {
+	body, err := encodeForm("resceAdmSnm", "A&B=C", "aplyDateS", "2021/10/01 10%", "pswdResceNewConfirm", "　検索　")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "resceAdmSnm=A%26B%3DC&aplyDateS=2021%2F10%2F01+10%25&pswdResceNewConfirm=%81%40%8C%9F%8D%F5%81%40"; body != want {
+		t.Fatalf("%s", body)
+	}
+}
@@ -0,0 +1,79 @@
+package jpnic
+
+import (
+	"context"
+)
+
+// Tracer はJPNICへの各処理(ログイン・メニューの取得・フォームの取得/送信・詳細の取得・解析)をスパンとして記録します。
+// OpenTelemetryのtrace.Tracer/trace.Spanと同じ形にしているため、薄いアダプタで既存のトレーシングに接続できます。
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span は1つの処理です。Endは必ず1度だけ呼び出されます。
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute はスパンの属性です。Valueはstring・int・bool・float64のいずれかです。
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attr はAttributeを返します。
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
+
+type tracerKey struct{}
+
+// startOperation は公開APIの処理(operation)のスパンをctx(呼び出し元)の子として開始します。
+// 内部の処理は返されたcontextを受け取り、startSpanで子のスパンを作成します。
+func (c *Config) startOperation(ctx context.Context, operation string, attrs ...Attribute) (context.Context, Span) {
+	var tracer Tracer = nopTracer{}
+	if c.Tracer != nil {
+		tracer = c.Tracer
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx = context.WithValue(ctx, tracerKey{}, tracer)
+	ctx = context.WithValue(ctx, operationKey{}, operationInfo{name: operation, captureDir: c.CaptureDir, logger: c.logger()})
+	return tracer.Start(ctx, "jpnic."+operation, append([]Attribute{Attr("jpnic.operation", operation)}, attrs...)...)
+}
+
+// startSpan はctxのスパンの子のスパンを開始します。ctxにTracerが無い場合は何もしません。
+func startSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	if ctx == nil {
+		return context.Background(), nopSpan{}
+	}
+	tracer, ok := ctx.Value(tracerKey{}).(Tracer)
+	if !ok {
+		return ctx, nopSpan{}
+	}
+	return tracer.Start(ctx, "jpnic."+name, attrs...)
+}
+
+// endSpan はerrがあれば記録し、スパンを終了します。
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
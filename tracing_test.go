@@ -0,0 +1,190 @@
+package jpnic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSpan struct {
+	name   string
+	parent *testSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  int
+}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+type testSpanKey struct{}
+
+func (t *testTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &testSpan{name: name, attrs: make(map[string]interface{})}
+	span.parent, _ = ctx.Value(testSpanKey{}).(*testSpan)
+	span.SetAttributes(attrs...)
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func (s *testSpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *testSpan) RecordError(err error) { s.err = err }
+func (s *testSpan) End()                  { s.ended++ }
+
+func TestTracingRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jpnic/notfound.do" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	tracer := &testTracer{}
+	con := Config{Tracer: tracer}
+	ctx, op := con.startOperation(context.Background(), "SearchIPv4", Attr("jpnic.detail", true))
+
+	r := newTestRequest(t, srv.URL+"/jpnic/entryinfo.do;jsessionid=ABCDEF")
+	r.Context = ctx
+	r.Step = "form.fetch"
+	resp, err := r.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	r = newTestRequest(t, srv.URL+"/jpnic/notfound.do")
+	r.Context = ctx
+	r.Step = "form.submit"
+	if _, err = r.post(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Stepが無いリクエストはスパンを作成しない
+	r = newTestRequest(t, srv.URL+"/jpnic/menu.do")
+	r.Context = ctx
+	resp, err = r.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	endSpan(op, nil)
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("spans: %d", len(tracer.spans))
+	}
+
+	root := tracer.spans[0]
+	if root.name != "jpnic.SearchIPv4" || root.attrs["jpnic.operation"] != "SearchIPv4" || root.attrs["jpnic.detail"] != true {
+		t.Errorf("root: %+v", root)
+	}
+
+	fetch := tracer.spans[1]
+	if fetch.name != "jpnic.form.fetch" || fetch.parent != root {
+		t.Errorf("fetch: %+v", fetch)
+	}
+	if fetch.attrs["http.method"] != "GET" || fetch.attrs["http.target"] != "/jpnic/entryinfo.do" || fetch.attrs["http.status_code"] != 200 {
+		t.Errorf("fetch attrs: %v", fetch.attrs)
+	}
+
+	submit := tracer.spans[2]
+	if submit.name != "jpnic.form.submit" || submit.err == nil || submit.attrs["http.status_code"] != 404 {
+		t.Errorf("submit: %+v", submit)
+	}
+
+	for _, span := range tracer.spans {
+		if span.ended != 1 {
+			t.Errorf("%s: ended %d", span.name, span.ended)
+		}
+	}
+}
+
+func TestTracingNop(t *testing.T) {
+	// Tracerが設定されていない場合は何も記録しない
+	_, span := (&Config{}).startOperation(context.Background(), "GetIPUser")
+	if _, ok := span.(nopSpan); !ok {
+		t.Fatal("expected nopSpan")
+	}
+
+	_, span = startSpan(context.Background(), "parse")
+	if _, ok := span.(nopSpan); !ok {
+		t.Fatal("expected nopSpan")
+	}
+}
+
+func TestTracingParentContext(t *testing.T) {
+	tracer := &testTracer{}
+	page := Interaction{
+		Request:  CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/form.do"},
+		Response: CassetteResponse{StatusCode: 200, Body: []byte(`<table><tr><td>資源管理者番号</td></tr></table>`)},
+	}
+
+	// 呼び出し元のスパンの子になる
+	ctx, caller := tracer.Start(context.Background(), "caller")
+	con := Config{Tracer: tracer, Cassette: newMenuCassette(t, "資源管理者情報", page)}
+	if _, _, err := con.GetResourceManagementContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	caller.End()
+
+	if len(tracer.spans) < 2 || tracer.spans[1].name != "jpnic.GetResourceManagement" || tracer.spans[1].parent != caller {
+		t.Fatalf("operation span is not a child of the caller: %+v", tracer.spans)
+	}
+
+	// キャンセル済みのctxでは通信しない
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	con = Config{Cassette: newMenuCassette(t, "資源管理者情報", page)}
+	if _, _, err := con.GetResourceManagementContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled: %v", err)
+	}
+}
+
+func TestTracingCancelDuringDetail(t *testing.T) {
+	tracer := &testTracer{}
+	con := Config{
+		Tracer: tracer,
+		Cassette: newMenuCassette(t, "登録情報検索(IPv4)",
+			Interaction{
+				Request: CassetteRequest{Method: "GET", URL: baseURL + "/jpnic/form.do"},
+				Response: CassetteResponse{StatusCode: 200,
+					Body: []byte(`<form action="/jpnic/search.do"><input name="destdisp" value="D1"></form>`)},
+			},
+			Interaction{
+				Request:  CassetteRequest{Method: "POST", URL: baseURL + "/jpnic/search.do"},
+				Response: CassetteResponse{StatusCode: 200, Body: []byte(searchIPv4Rows("/jpnic/detail.do"))},
+			},
+		),
+	}
+
+	// 詳細情報の取得前の待機中にキャンセルされた場合は、待たずにエラーを返す
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := con.SearchIPv4Context(ctx, SearchIPv4{IsDetail: true})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("elapsed: %s", elapsed)
+	}
+	if len(tracer.spans) == 0 || tracer.spans[0].name != "jpnic.SearchIPv4" || tracer.spans[0].err == nil {
+		t.Fatalf("operation span: %+v", tracer.spans)
+	}
+}
@@ -0,0 +1,316 @@
+package jpnic
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/width"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrMaintenance はJPNICのサイトがメンテナンス中の場合に返されます。errors.Isで判定してください。
+var ErrMaintenance = errors.New("JPNICはメンテナンス中です")
+
+// MaintenanceError はメンテナンス中の応答です。ページに終了予定時刻が記載されている場合はEndに設定されます。
+type MaintenanceError struct {
+	StatusCode int
+	// 終了予定時刻(記載が無い場合はゼロ値)
+	End time.Time
+}
+
+func (e *MaintenanceError) Error() string {
+	if e.End.IsZero() {
+		return fmt.Sprintf("[%d] 現在、メンテナンス中のためデータ取得が出来ません。", e.StatusCode)
+	}
+	return fmt.Sprintf("[%d] 現在、メンテナンス中のためデータ取得が出来ません。(終了予定: %s)", e.StatusCode, e.End.Format("2006/01/02 15:04"))
+}
+
+func (e *MaintenanceError) Is(target error) bool {
+	return target == ErrMaintenance
+}
+
+// RetryPolicy はHTTPリクエストの再試行の設定です。
+// 再試行するのはGET/HEADのみで、申請等のPOSTは失敗しても再試行しません。
+type RetryPolicy struct {
+	// 再試行回数(0の場合は3回、負の値の場合は再試行しない)
+	MaxRetries int
+	// 初回の待ち時間(0の場合は1秒)。再試行毎に2倍になります。
+	Wait time.Duration
+	// 待ち時間の上限(0の場合は30秒)
+	MaxWait time.Duration
+}
+
+func (p RetryPolicy) retries() int {
+	switch {
+	case p.MaxRetries < 0:
+		return 0
+	case p.MaxRetries == 0:
+		return 3
+	}
+	return p.MaxRetries
+}
+
+func (p RetryPolicy) waits() (time.Duration, time.Duration) {
+	wait, maxWait := p.Wait, p.MaxWait
+	if wait <= 0 {
+		wait = time.Second
+	}
+	if maxWait <= 0 {
+		maxWait = 30 * time.Second
+	}
+	return wait, maxWait
+}
+
+// retryTransport は全てのHTTPリクエストで共通の処理(圧縮の展開・メンテナンスの判定・再試行)を行います。
+// 各リクエストはメニュー名・パス・ステータス・所要時間・サイズをdebugレベルでログに出力します。
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+	logger Logger
+	// initAccessで開いたメニュー名(ログ用)
+	menu string
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		retries = t.policy.retries()
+	}
+	wait, maxWait := t.policy.waits()
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			t.logger.Log(LogDebug, "http", "menu", t.menu, "method", req.Method, "path", req.URL.Path,
+				"attempt", attempt+1, "duration", time.Since(start), "error", err)
+			if attempt >= retries || !retryableError(err) {
+				return nil, err
+			}
+		} else {
+			if err = decodeContent(resp); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			resp, err = checkMaintenance(resp)
+			if err != nil {
+				t.logger.Log(LogWarn, "メンテナンス中です", "menu", t.menu, "path", req.URL.Path, "error", err)
+				return nil, err
+			}
+			if attempt >= retries || !retryableStatus(resp.StatusCode) {
+				resp.Body = &loggingBody{ReadCloser: resp.Body, done: func(n int64) {
+					t.logger.Log(LogDebug, "http", "menu", t.menu, "method", req.Method, "path", req.URL.Path,
+						"attempt", attempt+1, "status", resp.StatusCode, "duration", time.Since(start), "bytes", n)
+				}}
+				return resp, nil
+			}
+			t.logger.Log(LogDebug, "http", "menu", t.menu, "method", req.Method, "path", req.URL.Path,
+				"attempt", attempt+1, "status", resp.StatusCode, "duration", time.Since(start))
+			if after := retryAfter(resp); after > wait {
+				wait = after
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if wait > maxWait {
+			wait = maxWait
+		}
+		t.logger.Log(LogInfo, "再試行します", "menu", t.menu, "path", req.URL.Path, "attempt", attempt+2, "wait", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// decodeContent はContent-Encoding(gzip・deflate・br)に従って本文を展開します。
+// Accept-Encodingを明示した場合、net/httpは展開しないためここで行います。
+func decodeContent(resp *http.Response) error {
+	encoding := resp.Header.Get("Content-Encoding")
+	if encoding == "" || resp.Uncompressed || resp.ContentLength == 0 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified ||
+		(resp.Request != nil && resp.Request.Method == http.MethodHead) {
+		return nil
+	}
+
+	// 複数指定されている場合は適用された順の逆に展開する
+	encodings := strings.Split(encoding, ",")
+	body := io.Reader(resp.Body)
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch strings.ToLower(strings.TrimSpace(encodings[i])) {
+		case "gzip", "x-gzip":
+			body, err = gzip.NewReader(body)
+		case "deflate":
+			body, err = newDeflateReader(body)
+		case "br":
+			body = brotli.NewReader(body)
+		case "identity", "":
+		default:
+			err = fmt.Errorf("対応していないContent-Encodingです: %s", encoding)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	resp.Body = &decodedBody{Reader: body, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// newDeflateReader はzlib形式(RFC 1950)のdeflateを展開します。zlibのヘッダが無い場合は生のdeflate(RFC 1951)として扱います。
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	buf := bufio.NewReader(r)
+	header, err := buf.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buf)
+	}
+	return flate.NewReader(buf), nil
+}
+
+// decodedBody は展開後の本文を読み込み、Closeで元の本文を閉じます。
+type decodedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	return b.body.Close()
+}
+
+// loggingBody は読み込んだサイズを数え、Closeの際にdoneを1度だけ呼び出します。
+type loggingBody struct {
+	io.ReadCloser
+	n    int64
+	done func(n int64)
+}
+
+func (b *loggingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *loggingBody) Close() error {
+	if b.done != nil {
+		b.done(b.n)
+		b.done = nil
+	}
+	return b.ReadCloser.Close()
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryableError は接続のリセット・切断・タイムアウトの場合にtrueを返します。
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfter はRetry-Afterヘッダ(秒数)の待ち時間を返します。
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// checkMaintenance は503の応答がメンテナンスのページの場合にMaintenanceErrorを返します。
+// メンテナンス以外の場合は、読み込んだ本文を戻した応答を返します。
+func checkMaintenance(resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		return resp, nil
+	}
+
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// メンテナンスのページは文字コードの指定が無い場合はEUC-JP
+	body, _, _ := decodeHTML(raw, resp.Header.Get("Content-Type"), japanese.EUCJP)
+	if strings.Contains(body, "メンテナンス中") {
+		return nil, &MaintenanceError{StatusCode: resp.StatusCode, End: parseMaintenanceEnd(body, time.Now())}
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	return resp, nil
+}
+
+var jst = time.FixedZone("JST", 9*60*60)
+
+// 日付(年は省略可)と時刻。"2021年10月1日(金) 9:00", "2021/10/01 18:00", "10月2日 18時30分" 等
+var maintenanceTimeRegexp = regexp.MustCompile(`(?:(?:(\d{4})[年/])?(\d{1,2})[月/](\d{1,2})日?\s*(?:\([^)]*\))?\s*)?(\d{1,2})(?::(\d{2})|時(?:(\d{1,2})分)?)`)
+
+// parseMaintenanceEnd はメンテナンスのページに記載された最後の日時を終了予定時刻として返します。(日本時間)
+// 時刻のみの記載("9:00～18:00"の18:00等)は直前の日付を使い、年の記載が無い場合はnowの年とします。
+func parseMaintenanceEnd(body string, now time.Time) time.Time {
+	body = width.Narrow.String(body)
+
+	var end time.Time
+	var year, month, day int
+	for _, match := range maintenanceTimeRegexp.FindAllStringSubmatch(body, -1) {
+		if match[2] != "" {
+			year = now.In(jst).Year()
+			if match[1] != "" {
+				year, _ = strconv.Atoi(match[1])
+			}
+			month, _ = strconv.Atoi(match[2])
+			day, _ = strconv.Atoi(match[3])
+		}
+		if month == 0 {
+			continue
+		}
+
+		hour, _ := strconv.Atoi(match[4])
+		minute, _ := strconv.Atoi(match[5] + match[6])
+		if hour > 24 || minute > 59 {
+			continue
+		}
+		end = time.Date(year, time.Month(month), day, hour, minute, 0, 0, jst)
+	}
+	return end
+}
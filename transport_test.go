@@ -0,0 +1,203 @@
+package jpnic
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding/japanese"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRequest(t *testing.T, url string) *request {
+	con := Config{
+		CertFilePath: "testdata/client.pem",
+		KeyFilePath:  "testdata/client.key",
+		Retry:        RetryPolicy{Wait: time.Millisecond},
+	}
+	client, err := con.newHTTPClient(nil, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &request{Client: client, URL: url, UserAgent: userAgent, ContentType: contentType}
+}
+
+func TestRetryGet(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	resp, err := newTestRequest(t, srv.URL).get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if count != 3 {
+		t.Fatalf("count: %d", count)
+	}
+}
+
+func TestRetryConnectionReset(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	resp, err := newTestRequest(t, srv.URL).get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if count != 2 {
+		t.Fatalf("count: %d", count)
+	}
+}
+
+func TestNoRetryPost(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	r := newTestRequest(t, srv.URL)
+	r.Body = "a=b"
+	if _, err := r.post(); err == nil {
+		t.Fatal("expected error")
+	}
+	if count != 1 {
+		t.Fatalf("count: %d", count)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	page, err := japanese.EUCJP.NewEncoder().String("<html><body>ただいまメンテナンス中です。<br>" +
+		"メンテナンス期間: ２０２１年１０月１日(金) ９:００ ～ １８:００</body></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	_, err = newTestRequest(t, srv.URL).get()
+	if !errors.Is(err, ErrMaintenance) {
+		t.Fatalf("expected ErrMaintenance: %v", err)
+	}
+	var maintenanceErr *MaintenanceError
+	if !errors.As(err, &maintenanceErr) {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, 10, 1, 18, 0, 0, 0, jst); !maintenanceErr.End.Equal(want) {
+		t.Fatalf("end: %s", maintenanceErr.End)
+	}
+	if count != 1 {
+		t.Fatalf("count: %d", count)
+	}
+}
+
+func TestParseMaintenanceEnd(t *testing.T) {
+	now := time.Date(2021, 9, 1, 0, 0, 0, 0, jst)
+	for body, want := range map[string]time.Time{
+		"2021/10/01 09:00 - 2021/10/02 18:30": time.Date(2021, 10, 2, 18, 30, 0, 0, jst),
+		"10月2日(土) 9時 ～ 10月3日(日) 17時30分":       time.Date(2021, 10, 3, 17, 30, 0, 0, jst),
+		"終了時刻は未定です":                           {},
+	} {
+		if end := parseMaintenanceEnd(body, now); !end.Equal(want) {
+			t.Errorf("%s: %s", body, end)
+		}
+	}
+}
+
+func TestDecodeContent(t *testing.T) {
+	page, err := japanese.ShiftJIS.NewEncoder().String("<html><body>圧縮されたページ</body></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compress := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser {
+			zw, _ := zlib.NewWriterLevel(w, zlib.DefaultCompression)
+			return zw
+		},
+		"br": func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+	}
+
+	for encoding, newWriter := range compress {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=Shift_JIS")
+			w.Header().Set("Content-Encoding", encoding)
+			cw := newWriter(w)
+			cw.Write([]byte(page))
+			cw.Close()
+		}))
+
+		resp, err := newTestRequest(t, srv.URL).get()
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		body, _, err := readHTML(resp)
+		resp.Body.Close()
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if body != "<html><body>圧縮されたページ</body></html>" {
+			t.Fatalf("%s: %q", encoding, body)
+		}
+	}
+}
+
+func TestDecodeContentRawDeflate(t *testing.T) {
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	fw.Write([]byte("raw deflate"))
+	fw.Close()
+
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Encoding": {"deflate"}},
+		Body:          ioutil.NopCloser(&buf),
+		ContentLength: int64(buf.Len()),
+	}
+	if err := decodeContent(resp); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "raw deflate" || resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("%q", body)
+	}
+}
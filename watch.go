@@ -0,0 +1,204 @@
+package jpnic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RequestStatus は申請一覧のステータスを表します。
+type RequestStatus int
+
+const (
+	RequestStatusUnknown     RequestStatus = iota
+	RequestStatusReceived                  // 受付
+	RequestStatusUnderReview               // 審議中
+	RequestStatusOnHold                    // 保留・差戻し等、申請者側の対応待ち
+	RequestStatusCompleted                 // 完了
+	RequestStatusRejected                  // 却下
+	RequestStatusWithdrawn                 // 取下げ
+)
+
+var requestStatusText = map[RequestStatus]string{
+	RequestStatusUnknown:     "不明",
+	RequestStatusReceived:    "受付",
+	RequestStatusUnderReview: "審議中",
+	RequestStatusOnHold:      "保留",
+	RequestStatusCompleted:   "完了",
+	RequestStatusRejected:    "却下",
+	RequestStatusWithdrawn:   "取下げ",
+}
+
+// ParseRequestStatus はJPNICの表示文字列からRequestStatusを判定します。
+// 「未完了」「完了待ち」等を完了と判定しないよう、否定・待ちの表現を先に判定します。
+func ParseRequestStatus(str string) RequestStatus {
+	switch {
+	case strings.Contains(str, "保留"), strings.Contains(str, "差戻"), strings.Contains(str, "差し戻"), strings.Contains(str, "待ち"):
+		return RequestStatusOnHold
+	case strings.Contains(str, "未完了"), strings.Contains(str, "未処理"):
+		return RequestStatusUnderReview
+	case strings.Contains(str, "完了"):
+		return RequestStatusCompleted
+	case strings.Contains(str, "却下"):
+		return RequestStatusRejected
+	case strings.Contains(str, "取下"), strings.Contains(str, "取り下"):
+		return RequestStatusWithdrawn
+	case strings.Contains(str, "審議"), strings.Contains(str, "審査"), strings.Contains(str, "処理中"):
+		return RequestStatusUnderReview
+	case strings.Contains(str, "受付"), strings.Contains(str, "受け付"):
+		return RequestStatusReceived
+	}
+	return RequestStatusUnknown
+}
+
+func (s RequestStatus) String() string {
+	return requestStatusText[s]
+}
+
+// MarshalText はJSON等でステータスを表示文字列("完了"等)として出力します。
+func (s RequestStatus) MarshalText() ([]byte, error) {
+	text, ok := requestStatusText[s]
+	if !ok {
+		return nil, fmt.Errorf("不明なステータスです: %d", int(s))
+	}
+	return []byte(text), nil
+}
+
+// UnmarshalText はMarshalTextで出力した表示文字列からステータスを復元します。
+func (s *RequestStatus) UnmarshalText(text []byte) error {
+	for status, str := range requestStatusText {
+		if str == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("不明なステータスです: %s", text)
+}
+
+// IsTerminal は以降ステータスが変化しない場合にtrueを返します。
+func (s RequestStatus) IsTerminal() bool {
+	return s == RequestStatusCompleted || s == RequestStatusRejected || s == RequestStatusWithdrawn
+}
+
+func (r RequestInfo) RequestStatus() RequestStatus {
+	return ParseRequestStatus(r.Status)
+}
+
+// RequestTransition は申請のステータスの変化です。
+type RequestTransition struct {
+	RecepNo string
+	From    RequestStatus
+	To      RequestStatus
+	Info    RequestInfo
+	Time    time.Time
+}
+
+// RequestWatcher は受付番号の申請一覧をバックオフしながら定期的に取得し、ステータスの変化を通知します。
+type RequestWatcher struct {
+	Config *Config
+	// 取得間隔の初期値(0の場合は1分)。変化が無い度に倍にし、MaxIntervalで頭打ちにします。
+	Interval time.Duration
+	// 取得間隔の最大値(0の場合は30分)
+	MaxInterval time.Duration
+	// 連続して取得に失敗した場合に諦める回数(0の場合は5回)
+	MaxErrors int
+	// ステータスが変化した際に呼ばれます。初回取得時はFromがRequestStatusUnknownになります。
+	OnTransition func(RequestTransition)
+
+	// テスト用
+	fetch func(ctx context.Context, recepNo string) ([]RequestInfo, error)
+}
+
+// WaitForRequest は受付番号の申請が完了・却下・取下げのいずれかになるまで待ちます。
+func (c *Config) WaitForRequest(ctx context.Context, recepNo string) (RequestInfo, error) {
+	w := RequestWatcher{Config: c}
+	return w.Watch(ctx, recepNo)
+}
+
+// Watch は申請が完了・却下・取下げのいずれかになるか、ctxがキャンセルされるまで監視します。
+// ctxがキャンセルされた場合は最後に取得した申請情報とctx.Err()を返します。
+func (w *RequestWatcher) Watch(ctx context.Context, recepNo string) (RequestInfo, error) {
+	recepNo = strings.TrimSpace(recepNo)
+	if recepNo == "" {
+		return RequestInfo{}, fmt.Errorf("受付番号が指定されていません")
+	}
+
+	interval := w.Interval
+	if interval == 0 {
+		interval = time.Minute
+	}
+	maxInterval := w.MaxInterval
+	if maxInterval == 0 {
+		maxInterval = 30 * time.Minute
+	}
+	maxErrors := w.MaxErrors
+	if maxErrors == 0 {
+		maxErrors = 5
+	}
+	fetch := w.fetch
+	if fetch == nil {
+		fetch = w.Config.GetRequestListContext
+	}
+
+	var last RequestInfo
+	status := RequestStatusUnknown
+	seen := false
+	wait := interval
+	errCount := 0
+
+	for {
+		infos, err := fetch(ctx, recepNo)
+		if err != nil {
+			errCount++
+			if errCount >= maxErrors {
+				return last, err
+			}
+		} else {
+			errCount = 0
+			if info, found := findRequest(infos, recepNo); found {
+				last = info
+				current := info.RequestStatus()
+				if !seen || current != status {
+					if w.OnTransition != nil {
+						w.OnTransition(RequestTransition{
+							RecepNo: recepNo,
+							From:    status,
+							To:      current,
+							Info:    info,
+							Time:    time.Now(),
+						})
+					}
+					seen = true
+					status = current
+					wait = interval
+				}
+				if status.IsTerminal() {
+					return last, nil
+				}
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return last, ctx.Err()
+		case <-timer.C:
+		}
+
+		wait *= 2
+		if wait > maxInterval {
+			wait = maxInterval
+		}
+	}
+}
+
+func findRequest(infos []RequestInfo, recepNo string) (RequestInfo, bool) {
+	for _, info := range infos {
+		if strings.TrimSpace(info.RecepNo) == recepNo {
+			return info, true
+		}
+	}
+	return RequestInfo{}, false
+}
@@ -0,0 +1,108 @@
+package jpnic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRequestStatus(t *testing.T) {
+	tests := map[string]RequestStatus{
+		"受付":   RequestStatusReceived,
+		"審議中":  RequestStatusUnderReview,
+		"保留":   RequestStatusOnHold,
+		"完了":   RequestStatusCompleted,
+		"却下":   RequestStatusRejected,
+		"取下げ":  RequestStatusWithdrawn,
+		"????": RequestStatusUnknown,
+		// 「完了」を含むが完了ではないもの
+		"未完了":      RequestStatusUnderReview,
+		"完了待ち":     RequestStatusOnHold,
+		"差戻し(未完了)": RequestStatusOnHold,
+		"申請者回答待ち":  RequestStatusOnHold,
+		"審議完了":     RequestStatusCompleted,
+	}
+	for str, want := range tests {
+		if got := ParseRequestStatus(str); got != want {
+			t.Errorf("%s: %s, want %s", str, got, want)
+		}
+	}
+}
+
+func TestRequestStatusJSON(t *testing.T) {
+	data, err := json.Marshal(MailNotification{Outcome: RequestStatusCompleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"outcome":"完了"`) {
+		t.Fatalf("json: %s", data)
+	}
+
+	var n MailNotification
+	if err = json.Unmarshal(data, &n); err != nil || n.Outcome != RequestStatusCompleted {
+		t.Fatalf("%v %v", n.Outcome, err)
+	}
+	if err = json.Unmarshal([]byte(`{"outcome":"????"}`), &n); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRequestWatcher(t *testing.T) {
+	statuses := []string{"受付", "受付", "審議中", "", "審議中", "完了"}
+	count := 0
+
+	var transitions []RequestTransition
+	w := RequestWatcher{
+		Interval:  time.Millisecond,
+		MaxErrors: 2,
+		OnTransition: func(transition RequestTransition) {
+			transitions = append(transitions, transition)
+		},
+		fetch: func(_ context.Context, recepNo string) ([]RequestInfo, error) {
+			status := statuses[count]
+			count++
+			if status == "" {
+				return nil, fmt.Errorf("temporary error")
+			}
+			return []RequestInfo{{RecepNo: "000000000000001"}, {RecepNo: recepNo, Status: status}}, nil
+		},
+	}
+
+	info, err := w.Watch(context.Background(), "020210901000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != "完了" || count != len(statuses) {
+		t.Fatalf("info: %+v, count: %d", info, count)
+	}
+
+	want := []RequestStatus{RequestStatusReceived, RequestStatusUnderReview, RequestStatusCompleted}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions: %+v", transitions)
+	}
+	for i, transition := range transitions {
+		if transition.To != want[i] || (i > 0 && transition.From != want[i-1]) {
+			t.Errorf("transition %d: %s -> %s", i, transition.From, transition.To)
+		}
+	}
+}
+
+func TestRequestWatcherCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	w := RequestWatcher{
+		Interval: time.Millisecond,
+		fetch: func(_ context.Context, recepNo string) ([]RequestInfo, error) {
+			return []RequestInfo{{RecepNo: recepNo, Status: "審議中"}}, nil
+		},
+	}
+
+	info, err := w.Watch(ctx, "020210901000001")
+	if err != context.DeadlineExceeded || info.Status != "審議中" {
+		t.Fatalf("info: %+v, err: %v", info, err)
+	}
+}
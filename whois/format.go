@@ -0,0 +1,188 @@
+package whois
+
+import (
+	"github.com/homenoc/jpnic-go"
+	"strings"
+	"unicode/utf8"
+)
+
+// 項目名の表示幅
+const labelWidth = 32
+
+// Policy は応答から伏せる個人情報の項目を指定します。
+type Policy struct {
+	Name          bool // 氏名(JPNICハンドルのみ。グループ名は伏せません)
+	Email         bool
+	Phone         bool
+	Fax           bool
+	NotifyAddress bool
+	// 伏せた項目に表示する文字列(空の場合は"(非公開)"/"(redacted)")
+	Mask string
+}
+
+type field struct {
+	label string
+	value string
+}
+
+type writer struct {
+	builder strings.Builder
+}
+
+func (w *writer) line(str string) {
+	w.builder.WriteString(str + "\n")
+}
+
+func (w *writer) fields(fields []field) {
+	for _, f := range fields {
+		label := f.label
+		if width := displayWidth(label); width < labelWidth {
+			label += strings.Repeat(" ", labelWidth-width)
+		} else {
+			label += " "
+		}
+		w.line(strings.TrimRight(label+f.value, " "))
+	}
+}
+
+func (w *writer) String() string {
+	return w.builder.String()
+}
+
+func (p Policy) mask(english bool) string {
+	if p.Mask != "" {
+		return p.Mask
+	}
+	if english {
+		return "(redacted)"
+	}
+	return "(非公開)"
+}
+
+func (p Policy) apply(detail jpnic.JPNICHandleDetail, english bool) jpnic.JPNICHandleDetail {
+	mask := p.mask(english)
+	redact := func(enabled bool, value *string) {
+		if enabled && *value != "" {
+			*value = mask
+		}
+	}
+
+	redact(p.Name && detail.IsJPNICHandle, &detail.Name)
+	redact(p.Name && detail.IsJPNICHandle, &detail.NameEn)
+	redact(p.Email, &detail.Email)
+	redact(p.Phone, &detail.Tel)
+	redact(p.Fax, &detail.Fax)
+	redact(p.NotifyAddress, &detail.NotifyAddress)
+	return detail
+}
+
+func (p Policy) applyNetwork(detail jpnic.InfoDetail, english bool) jpnic.InfoDetail {
+	if p.NotifyAddress && detail.NotifyAddress != "" {
+		detail.NotifyAddress = p.mask(english)
+	}
+	return detail
+}
+
+func formatNetwork(w *writer, detail jpnic.InfoDetail, english bool) {
+	if english {
+		w.line("Network Information:")
+		w.fields([]field{
+			{"a. [Network Number]", detail.IPAddress},
+			{"b. [Network Name]", detail.NetworkName},
+			{"g. [Organization]", detail.OrgEn},
+			{"m. [Administrative Contact]", detail.AdminJPNICHandle},
+			{"n. [Technical Contact]", detail.TechJPNICHandle},
+			{"p. [Nameserver]", detail.NameServer},
+			{"[Assigned Date]", detail.AssignDate},
+			{"[Return Date]", detail.ReturnDate},
+			{"[Last Update]", detail.UpdateDate},
+		})
+		return
+	}
+
+	w.fields([]field{{"Network Information:", "[ネットワーク情報]"}})
+	w.fields([]field{
+		{"a. [IPネットワークアドレス]", detail.IPAddress},
+		{"b. [ネットワーク名]", detail.NetworkName},
+		{"f. [組織名]", detail.Org},
+		{"g. [Organization]", detail.OrgEn},
+		{"m. [管理者連絡窓口]", detail.AdminJPNICHandle},
+		{"n. [技術連絡担当者]", detail.TechJPNICHandle},
+		{"p. [ネームサーバ]", detail.NameServer},
+		{"y. [通知アドレス]", detail.NotifyAddress},
+		{"[割当年月日]", detail.AssignDate},
+		{"[返却年月日]", detail.ReturnDate},
+		{"[最終更新]", detail.UpdateDate},
+	})
+}
+
+func formatHandle(w *writer, detail jpnic.JPNICHandleDetail, english bool) {
+	if english {
+		if detail.IsJPNICHandle {
+			w.line("Contact Information:")
+			w.fields([]field{
+				{"a. [JPNIC Handle]", detail.JPNICHandle},
+				{"c. [Last, First]", detail.NameEn},
+			})
+		} else {
+			w.line("Group Contact Information:")
+			w.fields([]field{
+				{"a. [Group Handle]", detail.JPNICHandle},
+				{"c. [Group Name]", detail.NameEn},
+			})
+		}
+		w.fields([]field{
+			{"d. [E-Mail]", detail.Email},
+			{"g. [Organization]", detail.OrgEn},
+			{"l. [Division]", detail.DivisionEn},
+			{"n. [Title]", detail.TitleEn},
+			{"o. [TEL]", detail.Tel},
+			{"p. [FAX]", detail.Fax},
+			{"y. [Reply Mail]", detail.NotifyAddress},
+			{"[Last Update]", detail.UpdateDate},
+		})
+		return
+	}
+
+	if detail.IsJPNICHandle {
+		w.fields([]field{{"Contact Information:", "[担当者情報]"}})
+		w.fields([]field{
+			{"a. [JPNICハンドル]", detail.JPNICHandle},
+			{"b. [氏名]", detail.Name},
+			{"c. [Last, First]", detail.NameEn},
+		})
+	} else {
+		w.fields([]field{{"Group Contact Information:", "[グループ連絡窓口情報]"}})
+		w.fields([]field{
+			{"a. [グループハンドル]", detail.JPNICHandle},
+			{"b. [グループ名]", detail.Name},
+			{"c. [Group Name]", detail.NameEn},
+		})
+	}
+	w.fields([]field{
+		{"d. [電子メイル]", detail.Email},
+		{"f. [組織名]", detail.Org},
+		{"g. [Organization]", detail.OrgEn},
+		{"k. [部署]", detail.Division},
+		{"l. [Division]", detail.DivisionEn},
+		{"m. [肩書]", detail.Title},
+		{"n. [Title]", detail.TitleEn},
+		{"o. [電話番号]", detail.Tel},
+		{"p. [FAX番号]", detail.Fax},
+		{"y. [通知アドレス]", detail.NotifyAddress},
+		{"[最終更新]", detail.UpdateDate},
+	})
+}
+
+// displayWidth は全角文字を2桁として表示幅を計算します。
+func displayWidth(str string) int {
+	width := 0
+	for _, r := range str {
+		if utf8.RuneLen(r) > 1 {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
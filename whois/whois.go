@@ -0,0 +1,139 @@
+package whois
+
+import (
+	"bufio"
+	"errors"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// クエリの最大長(RFC 3912ではCRLFで終端された1行)
+const maxQueryLength = 1024
+
+var timeout = 30 * time.Second
+
+// Server は同期済みのSnapshotを元にRFC 3912のWHOISクエリへ応答します。
+// クエリの末尾に"/e"を付けると英語で応答します。
+type Server struct {
+	Policy Policy
+
+	mu    sync.RWMutex
+	index *snapshot.Index
+}
+
+// SetSnapshot は応答に使うデータを差し替えます。
+func (s *Server) SetSnapshot(snap *snapshot.Snapshot) {
+	index := snapshot.NewIndex(snap)
+
+	s.mu.Lock()
+	s.index = index
+	s.mu.Unlock()
+}
+
+// ListenAndServe はaddrでTCP接続を待ち受けます。
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve はlで接続を受け付けます。lがCloseされた場合はnet.ErrClosedを返して終了します。
+// それ以外のAcceptのエラー(ファイルディスクリプタの枯渇等)は、net/httpのServerと同様に待ち時間を倍にしながら(最大1秒)受付を続けます。
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+
+	var wait time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			if wait == 0 {
+				wait = 5 * time.Millisecond
+			} else {
+				wait *= 2
+			}
+			if wait > time.Second {
+				wait = time.Second
+			}
+			time.Sleep(wait)
+			continue
+		}
+		wait = 0
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	reader := bufio.NewReaderSize(conn, maxQueryLength)
+	line, isPrefix, err := reader.ReadLine()
+	if err != nil || isPrefix {
+		return
+	}
+
+	response := s.Lookup(string(line))
+	conn.Write([]byte(strings.ReplaceAll(response, "\n", "\r\n")))
+}
+
+// Lookup はIPアドレス、プレフィックス、ネットワーク名、JPNICハンドルのいずれかで検索し、応答文字列を返します。
+func (s *Server) Lookup(query string) string {
+	query = strings.TrimSpace(query)
+
+	english := false
+	if strings.HasSuffix(query, "/e") {
+		english = true
+		query = strings.TrimSpace(strings.TrimSuffix(query, "/e"))
+	}
+
+	w := &writer{}
+	if english {
+		w.line("[ This WHOIS server answers from data synchronized with the JPNIC database. ]")
+	} else {
+		w.line("[ このWHOISサーバはJPNICから同期したデータを元に応答しています。 ]")
+	}
+	w.line("")
+
+	s.mu.RLock()
+	index := s.index
+	s.mu.RUnlock()
+
+	if index == nil {
+		w.line("No match!!")
+		return w.String()
+	}
+
+	found := false
+
+	if handle, ok := index.Handle(query); ok {
+		formatHandle(w, s.Policy.apply(handle, english), english)
+		found = true
+	} else if snapshot.IsAddress(query) {
+		if detail, ok := index.Network(query); ok {
+			formatNetwork(w, s.Policy.applyNetwork(detail, english), english)
+			found = true
+		}
+	} else {
+		for _, detail := range index.NetworksByName(query) {
+			if found {
+				w.line("")
+			}
+			formatNetwork(w, s.Policy.applyNetwork(detail, english), english)
+			found = true
+		}
+	}
+
+	if !found {
+		w.line("No match!!")
+	}
+
+	return w.String()
+}
@@ -0,0 +1,168 @@
+package whois
+
+import (
+	"bufio"
+	"errors"
+	"github.com/homenoc/jpnic-go"
+	"github.com/homenoc/jpnic-go/snapshot"
+	"io/ioutil"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func testServer() *Server {
+	s := &Server{}
+	s.SetSnapshot(&snapshot.Snapshot{
+		IPv4: []jpnic.InfoIPv4{
+			{
+				IPAddress:   "192.0.2.0/24",
+				NetworkName: "EXAMPLE-NET",
+				InfoDetail: jpnic.InfoDetail{
+					IPAddress:        "192.0.2.0/24",
+					NetworkName:      "EXAMPLE-NET",
+					Org:              "例示株式会社",
+					OrgEn:            "Example Inc.",
+					AdminJPNICHandle: "YY38053JP",
+					TechJPNICHandle:  "YY36773JP",
+					NotifyAddress:    "noc@example.jp",
+				},
+			},
+			{IPAddress: "192.0.2.128/25", NetworkName: "EXAMPLE-SUB"},
+		},
+		IPv6: []jpnic.InfoIPv6{
+			{IPAddress: "2001:db8::/48", NetworkName: "EXAMPLE-V6"},
+		},
+		Handles: []jpnic.JPNICHandleDetail{
+			{
+				IsJPNICHandle: true,
+				JPNICHandle:   "YY38053JP",
+				Name:          "山田 太郎",
+				NameEn:        "Yamada, Taro",
+				Email:         "taro@example.jp",
+				Tel:           "03-0000-0000",
+			},
+			{
+				IsJPNICHandle: false,
+				JPNICHandle:   "YY36773JP",
+				Name:          "ネットワーク運用グループ",
+				Email:         "noc@example.jp",
+			},
+		},
+	})
+	return s
+}
+
+func TestLookupNetwork(t *testing.T) {
+	s := testServer()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"192.0.2.1", "EXAMPLE-NET"},
+		{"192.0.2.200", "EXAMPLE-SUB"},
+		{"192.0.2.0/24", "EXAMPLE-NET"},
+		{"192.0.2.128/26", "EXAMPLE-SUB"},
+		{"2001:db8::1", "EXAMPLE-V6"},
+		{"example-v6", "2001:db8::/48"},
+		{"198.51.100.1", "No match!!"},
+	}
+
+	for _, tt := range tests {
+		if out := s.Lookup(tt.query); !strings.Contains(out, tt.want) {
+			t.Errorf("%s: want %s\n%s", tt.query, tt.want, out)
+		}
+	}
+}
+
+func TestLookupFormat(t *testing.T) {
+	s := testServer()
+
+	out := s.Lookup("192.0.2.1")
+	if !strings.Contains(out, "a. [IPネットワークアドレス]     192.0.2.0/24\n") {
+		t.Errorf("japanese output:\n%s", out)
+	}
+
+	out = s.Lookup("192.0.2.1/e")
+	if !strings.Contains(out, "g. [Organization]               Example Inc.\n") || strings.Contains(out, "例示株式会社") {
+		t.Errorf("english output:\n%s", out)
+	}
+}
+
+func TestLookupRedaction(t *testing.T) {
+	s := testServer()
+	s.Policy = Policy{Name: true, Email: true, Phone: true}
+
+	out := s.Lookup("yy38053jp")
+	for _, secret := range []string{"山田", "taro@example.jp", "03-0000-0000"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%s is not redacted:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "(非公開)") {
+		t.Errorf("mask not found:\n%s", out)
+	}
+
+	// グループ名は個人情報ではないため伏せない
+	out = s.Lookup("YY36773JP")
+	if !strings.Contains(out, "ネットワーク運用グループ") || strings.Contains(out, "noc@example.jp") {
+		t.Errorf("group output:\n%s", out)
+	}
+}
+
+func TestServe(t *testing.T) {
+	s := testServer()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("EXAMPLE-NET\r\n"))
+	out, err := ioutil.ReadAll(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "192.0.2.0/24\r\n") {
+		t.Fatal(string(out))
+	}
+}
+
+// errListener はerrsを順に返した後、Closeされた場合と同じエラーを返します。
+type errListener struct {
+	net.Listener
+	errs []error
+}
+
+func (l *errListener) Accept() (net.Conn, error) {
+	if len(l.errs) != 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+		return nil, err
+	}
+	return nil, &net.OpError{Op: "accept", Net: "tcp", Err: net.ErrClosed}
+}
+
+func (l *errListener) Close() error { return nil }
+
+func TestServeAcceptError(t *testing.T) {
+	// ファイルディスクリプタの枯渇等では終了せず、Closeされた場合のみ終了する
+	l := &errListener{errs: []error{
+		&net.OpError{Op: "accept", Net: "tcp", Err: syscall.EMFILE},
+		&net.OpError{Op: "accept", Net: "tcp", Err: syscall.ECONNABORTED},
+	}}
+	err := testServer().Serve(l)
+	if !errors.Is(err, net.ErrClosed) || len(l.errs) != 0 {
+		t.Fatalf("%v (remaining %d)", err, len(l.errs))
+	}
+}